@@ -1,6 +1,7 @@
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -9,11 +10,135 @@ import (
 type SandboxSpec struct {
 	Owners []string `json:"owners"`
 	Size   string   `json:"size"`
+
+	// Budget is the most the Sandbox may cost over its lifetime, as a decimal in
+	// the currency of the configured prices. Defaults to the budget of its size.
+	Budget string `json:"budget,omitempty"`
+}
+
+// SandboxPhase is the lifecycle phase of a Sandbox
+type SandboxPhase string
+
+const (
+	// SandboxPhaseActive means the Sandbox has been provisioned
+	SandboxPhaseActive SandboxPhase = "Active"
+
+	// SandboxPhaseTerminating means the Sandbox is being torn down
+	SandboxPhaseTerminating SandboxPhase = "Terminating"
+
+	// SandboxPhaseHibernated means the Sandbox is over its budget and no new pods can be created
+	SandboxPhaseHibernated SandboxPhase = "Hibernated"
+
+	// SandboxPhaseAwaitingApproval means the Sandbox will not be provisioned until it is approved
+	SandboxPhaseAwaitingApproval SandboxPhase = "AwaitingApproval"
+
+	// SandboxPhaseRejected means the Sandbox was rejected and will be deleted
+	SandboxPhaseRejected SandboxPhase = "Rejected"
+)
+
+// SandboxConditionType is the type of a condition of a Sandbox
+type SandboxConditionType string
+
+const (
+	// SandboxConditionQuotaPressure is true when a resource of the ResourceQuota is used above the configured threshold
+	SandboxConditionQuotaPressure SandboxConditionType = "QuotaPressure"
+
+	// SandboxConditionBudgetExceeded is true when the cost of the Sandbox has reached its budget
+	SandboxConditionBudgetExceeded SandboxConditionType = "BudgetExceeded"
+
+	// SandboxConditionResizePending is true when a resize of the Sandbox is waiting for approval
+	SandboxConditionResizePending SandboxConditionType = "ResizePending"
+
+	// SandboxConditionApproved is true once the creation of the Sandbox has been approved
+	SandboxConditionApproved SandboxConditionType = "Approved"
+
+	// SandboxConditionSourceReady is true when the source of a SandboxSharedResource
+	// can be copied into sandboxes
+	SandboxConditionSourceReady SandboxConditionType = "SourceReady"
+)
+
+// SandboxCondition describes the state of a Sandbox at a certain point
+// +k8s:openapi-gen=true
+type SandboxCondition struct {
+	Type               SandboxConditionType   `json:"type"`
+	Status             corev1.ConditionStatus `json:"status"`
+	Reason             string                 `json:"reason,omitempty"`
+	Message            string                 `json:"message,omitempty"`
+	LastTransitionTime metav1.Time            `json:"lastTransitionTime,omitempty"`
+}
+
+// SandboxCost is the cost of a Sandbox accrued over its lifetime.
+// Costs are decimal strings in the currency of the configured prices.
+// +k8s:openapi-gen=true
+type SandboxCost struct {
+	// Reserved is the cost of the resources reserved by the ResourceQuota
+	Reserved string `json:"reserved,omitempty"`
+
+	// ReservedHourly is the current hourly cost of the resources reserved by the ResourceQuota
+	ReservedHourly string `json:"reservedHourly,omitempty"`
+
+	// Requested is the cost of the resources requested by workloads in the sandbox
+	Requested string `json:"requested,omitempty"`
+
+	// RequestedHourly is the current hourly cost of the resources requested by workloads in the sandbox
+	RequestedHourly string `json:"requestedHourly,omitempty"`
+
+	// LastAccrued is when the costs were last accrued
+	LastAccrued *metav1.Time `json:"lastAccrued,omitempty"`
+}
+
+// SandboxBudget is the budget of a Sandbox and how much of it has been spent
+// +k8s:openapi-gen=true
+type SandboxBudget struct {
+	Limit     string `json:"limit"`
+	Spent     string `json:"spent"`
+	Remaining string `json:"remaining"`
+}
+
+// SandboxResize records a change to the size of a Sandbox
+// +k8s:openapi-gen=true
+type SandboxResize struct {
+	FromSize string `json:"fromSize"`
+	ToSize   string `json:"toSize"`
+
+	// Decision is Applied for resizes that did not need approval, otherwise Approved or Rejected
+	Decision string      `json:"decision"`
+	Time     metav1.Time `json:"time"`
 }
 
 // SandboxStatus defines the observed state of Sandbox
 // +k8s:openapi-gen=true
-type SandboxStatus struct{}
+type SandboxStatus struct {
+	Phase SandboxPhase `json:"phase,omitempty"`
+
+	// ObservedGeneration is the generation of the Sandbox that was last provisioned
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// TeardownStep is the teardown step in progress while the Sandbox is terminating
+	TeardownStep string `json:"teardownStep,omitempty"`
+
+	// StuckResources lists the resources that are preventing the namespace from terminating
+	StuckResources []string `json:"stuckResources,omitempty"`
+
+	// QuotaUsage is the percentage of each resource of the ResourceQuota that is used
+	QuotaUsage map[string]int64 `json:"quotaUsage,omitempty"`
+
+	Conditions []SandboxCondition `json:"conditions,omitempty"`
+
+	Cost *SandboxCost `json:"cost,omitempty"`
+
+	Budget *SandboxBudget `json:"budget,omitempty"`
+
+	// Size is the size of the ResourceQuota that is applied, which lags behind
+	// the size in the spec while a resize is waiting for approval
+	Size string `json:"size,omitempty"`
+
+	// ResizeHistory lists the most recent resizes of the Sandbox
+	ResizeHistory []SandboxResize `json:"resizeHistory,omitempty"`
+
+	// DeletedBy is the user that deleted the Sandbox, as recorded by the webhook
+	DeletedBy string `json:"deletedBy,omitempty"`
+}
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
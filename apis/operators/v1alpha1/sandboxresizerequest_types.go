@@ -0,0 +1,63 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SandboxResizeDecision is the decision of an approver on a SandboxResizeRequest
+type SandboxResizeDecision string
+
+const (
+	// SandboxResizeApproved applies the new size to the Sandbox
+	SandboxResizeApproved SandboxResizeDecision = "Approved"
+
+	// SandboxResizeRejected keeps the current size of the Sandbox
+	SandboxResizeRejected SandboxResizeDecision = "Rejected"
+)
+
+// SandboxResizeRequestSpec defines the desired state of SandboxResizeRequest
+// +k8s:openapi-gen=true
+type SandboxResizeRequestSpec struct {
+	// Sandbox is the name of the Sandbox to resize
+	Sandbox string `json:"sandbox"`
+
+	FromSize string `json:"fromSize"`
+	ToSize   string `json:"toSize"`
+
+	// Decision is set by an approver to approve or reject the resize
+	Decision SandboxResizeDecision `json:"decision,omitempty"`
+
+	// Reason optionally explains the decision
+	Reason string `json:"reason,omitempty"`
+}
+
+// SandboxResizeRequestStatus defines the observed state of SandboxResizeRequest
+// +k8s:openapi-gen=true
+type SandboxResizeRequestStatus struct{}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// SandboxResizeRequest is created by the operator when a Sandbox is resized to
+// a size that needs to be approved
+// +k8s:openapi-gen=true
+// +kubebuilder:subresource:status
+type SandboxResizeRequest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SandboxResizeRequestSpec   `json:"spec,omitempty"`
+	Status SandboxResizeRequestStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// SandboxResizeRequestList contains a list of SandboxResizeRequest
+type SandboxResizeRequestList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SandboxResizeRequest `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SandboxResizeRequest{}, &SandboxResizeRequestList{})
+}
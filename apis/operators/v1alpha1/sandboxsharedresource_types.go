@@ -0,0 +1,56 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SandboxSharedResourceSpec defines the desired state of SandboxSharedResource
+// +k8s:openapi-gen=true
+type SandboxSharedResourceSpec struct {
+	// Kind is the kind of the source object, either Secret or ConfigMap
+	Kind string `json:"kind"`
+
+	SourceName      string `json:"sourceName"`
+	SourceNamespace string `json:"sourceNamespace"`
+
+	// TargetName is the name of the copy in each sandbox. Defaults to SourceName.
+	TargetName string `json:"targetName,omitempty"`
+
+	// SandboxSelector selects the sandboxes the resource is copied into.
+	// All sandboxes are selected when it is empty.
+	SandboxSelector *metav1.LabelSelector `json:"sandboxSelector,omitempty"`
+}
+
+// SandboxSharedResourceStatus defines the observed state of SandboxSharedResource
+// +k8s:openapi-gen=true
+type SandboxSharedResourceStatus struct {
+	// Conditions holds the SourceReady condition, which is false when the
+	// resource cannot be copied into sandboxes
+	Conditions []SandboxCondition `json:"conditions,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// SandboxSharedResource is the Schema for the sandboxsharedresources API
+// +k8s:openapi-gen=true
+// +kubebuilder:subresource:status
+type SandboxSharedResource struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SandboxSharedResourceSpec   `json:"spec,omitempty"`
+	Status SandboxSharedResourceStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// SandboxSharedResourceList contains a list of SandboxSharedResource
+type SandboxSharedResourceList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SandboxSharedResource `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SandboxSharedResource{}, &SandboxSharedResourceList{})
+}
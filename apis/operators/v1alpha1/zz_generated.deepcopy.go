@@ -5,6 +5,7 @@
 package v1alpha1
 
 import (
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -14,7 +15,7 @@ func (in *Sandbox) DeepCopyInto(out *Sandbox) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -36,6 +37,59 @@ func (in *Sandbox) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SandboxBudget) DeepCopyInto(out *SandboxBudget) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SandboxBudget.
+func (in *SandboxBudget) DeepCopy() *SandboxBudget {
+	if in == nil {
+		return nil
+	}
+	out := new(SandboxBudget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SandboxCondition) DeepCopyInto(out *SandboxCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SandboxCondition.
+func (in *SandboxCondition) DeepCopy() *SandboxCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(SandboxCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SandboxCost) DeepCopyInto(out *SandboxCost) {
+	*out = *in
+	if in.LastAccrued != nil {
+		in, out := &in.LastAccrued, &out.LastAccrued
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SandboxCost.
+func (in *SandboxCost) DeepCopy() *SandboxCost {
+	if in == nil {
+		return nil
+	}
+	out := new(SandboxCost)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SandboxList) DeepCopyInto(out *SandboxList) {
 	*out = *in
@@ -69,6 +123,221 @@ func (in *SandboxList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SandboxResize) DeepCopyInto(out *SandboxResize) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SandboxResize.
+func (in *SandboxResize) DeepCopy() *SandboxResize {
+	if in == nil {
+		return nil
+	}
+	out := new(SandboxResize)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SandboxResizeRequest) DeepCopyInto(out *SandboxResizeRequest) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	out.Status = in.Status
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SandboxResizeRequest.
+func (in *SandboxResizeRequest) DeepCopy() *SandboxResizeRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(SandboxResizeRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SandboxResizeRequest) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SandboxResizeRequestList) DeepCopyInto(out *SandboxResizeRequestList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	out.ListMeta = in.ListMeta
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]SandboxResizeRequest, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SandboxResizeRequestList.
+func (in *SandboxResizeRequestList) DeepCopy() *SandboxResizeRequestList {
+	if in == nil {
+		return nil
+	}
+	out := new(SandboxResizeRequestList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SandboxResizeRequestList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SandboxResizeRequestSpec) DeepCopyInto(out *SandboxResizeRequestSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SandboxResizeRequestSpec.
+func (in *SandboxResizeRequestSpec) DeepCopy() *SandboxResizeRequestSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SandboxResizeRequestSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SandboxResizeRequestStatus) DeepCopyInto(out *SandboxResizeRequestStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SandboxResizeRequestStatus.
+func (in *SandboxResizeRequestStatus) DeepCopy() *SandboxResizeRequestStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SandboxResizeRequestStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SandboxSharedResource) DeepCopyInto(out *SandboxSharedResource) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SandboxSharedResource.
+func (in *SandboxSharedResource) DeepCopy() *SandboxSharedResource {
+	if in == nil {
+		return nil
+	}
+	out := new(SandboxSharedResource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SandboxSharedResource) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SandboxSharedResourceList) DeepCopyInto(out *SandboxSharedResourceList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	out.ListMeta = in.ListMeta
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]SandboxSharedResource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SandboxSharedResourceList.
+func (in *SandboxSharedResourceList) DeepCopy() *SandboxSharedResourceList {
+	if in == nil {
+		return nil
+	}
+	out := new(SandboxSharedResourceList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SandboxSharedResourceList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SandboxSharedResourceSpec) DeepCopyInto(out *SandboxSharedResourceSpec) {
+	*out = *in
+	if in.SandboxSelector != nil {
+		in, out := &in.SandboxSelector, &out.SandboxSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SandboxSharedResourceSpec.
+func (in *SandboxSharedResourceSpec) DeepCopy() *SandboxSharedResourceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SandboxSharedResourceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SandboxSharedResourceStatus) DeepCopyInto(out *SandboxSharedResourceStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]SandboxCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SandboxSharedResourceStatus.
+func (in *SandboxSharedResourceStatus) DeepCopy() *SandboxSharedResourceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SandboxSharedResourceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SandboxSpec) DeepCopyInto(out *SandboxSpec) {
 	*out = *in
@@ -93,6 +362,42 @@ func (in *SandboxSpec) DeepCopy() *SandboxSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SandboxStatus) DeepCopyInto(out *SandboxStatus) {
 	*out = *in
+	if in.StuckResources != nil {
+		in, out := &in.StuckResources, &out.StuckResources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.QuotaUsage != nil {
+		in, out := &in.QuotaUsage, &out.QuotaUsage
+		*out = make(map[string]int64, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]SandboxCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Cost != nil {
+		in, out := &in.Cost, &out.Cost
+		*out = new(SandboxCost)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Budget != nil {
+		in, out := &in.Budget, &out.Budget
+		*out = new(SandboxBudget)
+		**out = **in
+	}
+	if in.ResizeHistory != nil {
+		in, out := &in.ResizeHistory, &out.ResizeHistory
+		*out = make([]SandboxResize, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -11,9 +11,19 @@ import (
 
 func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenAPIDefinition {
 	return map[string]common.OpenAPIDefinition{
-		"./pkg/apis/operators/v1alpha1.Sandbox":       schema_pkg_apis_operators_v1alpha1_Sandbox(ref),
-		"./pkg/apis/operators/v1alpha1.SandboxSpec":   schema_pkg_apis_operators_v1alpha1_SandboxSpec(ref),
-		"./pkg/apis/operators/v1alpha1.SandboxStatus": schema_pkg_apis_operators_v1alpha1_SandboxStatus(ref),
+		"./pkg/apis/operators/v1alpha1.Sandbox":                     schema_pkg_apis_operators_v1alpha1_Sandbox(ref),
+		"./pkg/apis/operators/v1alpha1.SandboxBudget":               schema_pkg_apis_operators_v1alpha1_SandboxBudget(ref),
+		"./pkg/apis/operators/v1alpha1.SandboxCondition":            schema_pkg_apis_operators_v1alpha1_SandboxCondition(ref),
+		"./pkg/apis/operators/v1alpha1.SandboxCost":                 schema_pkg_apis_operators_v1alpha1_SandboxCost(ref),
+		"./pkg/apis/operators/v1alpha1.SandboxResize":               schema_pkg_apis_operators_v1alpha1_SandboxResize(ref),
+		"./pkg/apis/operators/v1alpha1.SandboxResizeRequest":        schema_pkg_apis_operators_v1alpha1_SandboxResizeRequest(ref),
+		"./pkg/apis/operators/v1alpha1.SandboxResizeRequestSpec":    schema_pkg_apis_operators_v1alpha1_SandboxResizeRequestSpec(ref),
+		"./pkg/apis/operators/v1alpha1.SandboxResizeRequestStatus":  schema_pkg_apis_operators_v1alpha1_SandboxResizeRequestStatus(ref),
+		"./pkg/apis/operators/v1alpha1.SandboxSharedResource":       schema_pkg_apis_operators_v1alpha1_SandboxSharedResource(ref),
+		"./pkg/apis/operators/v1alpha1.SandboxSharedResourceSpec":   schema_pkg_apis_operators_v1alpha1_SandboxSharedResourceSpec(ref),
+		"./pkg/apis/operators/v1alpha1.SandboxSharedResourceStatus": schema_pkg_apis_operators_v1alpha1_SandboxSharedResourceStatus(ref),
+		"./pkg/apis/operators/v1alpha1.SandboxSpec":                 schema_pkg_apis_operators_v1alpha1_SandboxSpec(ref),
+		"./pkg/apis/operators/v1alpha1.SandboxStatus":               schema_pkg_apis_operators_v1alpha1_SandboxStatus(ref),
 	}
 }
 
@@ -60,6 +70,388 @@ func schema_pkg_apis_operators_v1alpha1_Sandbox(ref common.ReferenceCallback) co
 	}
 }
 
+func schema_pkg_apis_operators_v1alpha1_SandboxBudget(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "SandboxBudget is the budget of a Sandbox and how much of it has been spent",
+				Properties: map[string]spec.Schema{
+					"limit": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"spent": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"remaining": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+				},
+				Required: []string{"limit", "spent", "remaining"},
+			},
+		},
+		Dependencies: []string{},
+	}
+}
+
+func schema_pkg_apis_operators_v1alpha1_SandboxCondition(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "SandboxCondition describes the state of a Sandbox at a certain point",
+				Properties: map[string]spec.Schema{
+					"type": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"status": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"reason": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"message": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"lastTransitionTime": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
+						},
+					},
+				},
+				Required: []string{"type", "status"},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.Time"},
+	}
+}
+
+func schema_pkg_apis_operators_v1alpha1_SandboxCost(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "SandboxCost is the cost of a Sandbox accrued over its lifetime. Costs are decimal strings in the currency of the configured prices.",
+				Properties: map[string]spec.Schema{
+					"reserved": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Reserved is the cost of the resources reserved by the ResourceQuota",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"reservedHourly": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ReservedHourly is the current hourly cost of the resources reserved by the ResourceQuota",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"requested": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Requested is the cost of the resources requested by workloads in the sandbox",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"requestedHourly": {
+						SchemaProps: spec.SchemaProps{
+							Description: "RequestedHourly is the current hourly cost of the resources requested by workloads in the sandbox",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"lastAccrued": {
+						SchemaProps: spec.SchemaProps{
+							Description: "LastAccrued is when the costs were last accrued",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.Time"},
+	}
+}
+
+func schema_pkg_apis_operators_v1alpha1_SandboxResize(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "SandboxResize records a change to the size of a Sandbox",
+				Properties: map[string]spec.Schema{
+					"fromSize": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"toSize": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"decision": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Decision is Applied for resizes that did not need approval, otherwise Approved or Rejected",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"time": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
+						},
+					},
+				},
+				Required: []string{"fromSize", "toSize", "decision", "time"},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.Time"},
+	}
+}
+
+func schema_pkg_apis_operators_v1alpha1_SandboxResizeRequest(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "SandboxResizeRequest is created by the operator when a Sandbox is resized to a size that needs to be approved",
+				Properties: map[string]spec.Schema{
+					"kind": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Kind is a string value representing the REST resource this object represents. Servers may infer this from the endpoint the client submits requests to. Cannot be updated. In CamelCase. More info: https://git.k8s.io/community/contributors/devel/api-conventions.md#types-kinds",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"apiVersion": {
+						SchemaProps: spec.SchemaProps{
+							Description: "APIVersion defines the versioned schema of this representation of an object. Servers should convert recognized schemas to the latest internal value, and may reject unrecognized values. More info: https://git.k8s.io/community/contributors/devel/api-conventions.md#resources",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"metadata": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"),
+						},
+					},
+					"spec": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("./pkg/apis/operators/v1alpha1.SandboxResizeRequestSpec"),
+						},
+					},
+					"status": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("./pkg/apis/operators/v1alpha1.SandboxResizeRequestStatus"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"./pkg/apis/operators/v1alpha1.SandboxResizeRequestSpec", "./pkg/apis/operators/v1alpha1.SandboxResizeRequestStatus", "k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"},
+	}
+}
+
+func schema_pkg_apis_operators_v1alpha1_SandboxResizeRequestSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "SandboxResizeRequestSpec defines the desired state of SandboxResizeRequest",
+				Properties: map[string]spec.Schema{
+					"sandbox": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Sandbox is the name of the Sandbox to resize",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"fromSize": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"toSize": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"decision": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Decision is set by an approver to approve or reject the resize",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"reason": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Reason optionally explains the decision",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+				},
+				Required: []string{"sandbox", "fromSize", "toSize"},
+			},
+		},
+		Dependencies: []string{},
+	}
+}
+
+func schema_pkg_apis_operators_v1alpha1_SandboxResizeRequestStatus(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "SandboxResizeRequestStatus defines the observed state of SandboxResizeRequest",
+				Properties:  map[string]spec.Schema{},
+			},
+		},
+		Dependencies: []string{},
+	}
+}
+
+func schema_pkg_apis_operators_v1alpha1_SandboxSharedResource(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "SandboxSharedResource is the Schema for the sandboxsharedresources API",
+				Properties: map[string]spec.Schema{
+					"kind": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Kind is a string value representing the REST resource this object represents. Servers may infer this from the endpoint the client submits requests to. Cannot be updated. In CamelCase. More info: https://git.k8s.io/community/contributors/devel/api-conventions.md#types-kinds",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"apiVersion": {
+						SchemaProps: spec.SchemaProps{
+							Description: "APIVersion defines the versioned schema of this representation of an object. Servers should convert recognized schemas to the latest internal value, and may reject unrecognized values. More info: https://git.k8s.io/community/contributors/devel/api-conventions.md#resources",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"metadata": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"),
+						},
+					},
+					"spec": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("./pkg/apis/operators/v1alpha1.SandboxSharedResourceSpec"),
+						},
+					},
+					"status": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("./pkg/apis/operators/v1alpha1.SandboxSharedResourceStatus"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"./pkg/apis/operators/v1alpha1.SandboxSharedResourceSpec", "./pkg/apis/operators/v1alpha1.SandboxSharedResourceStatus", "k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"},
+	}
+}
+
+func schema_pkg_apis_operators_v1alpha1_SandboxSharedResourceSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "SandboxSharedResourceSpec defines the desired state of SandboxSharedResource",
+				Properties: map[string]spec.Schema{
+					"kind": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Kind is the kind of the source object, either Secret or ConfigMap",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"sourceName": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"sourceNamespace": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"targetName": {
+						SchemaProps: spec.SchemaProps{
+							Description: "TargetName is the name of the copy in each sandbox. Defaults to SourceName.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"sandboxSelector": {
+						SchemaProps: spec.SchemaProps{
+							Description: "SandboxSelector selects the sandboxes the resource is copied into. All sandboxes are selected when it is empty.",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.LabelSelector"),
+						},
+					},
+				},
+				Required: []string{"kind", "sourceName", "sourceNamespace"},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.LabelSelector"},
+	}
+}
+
+func schema_pkg_apis_operators_v1alpha1_SandboxSharedResourceStatus(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "SandboxSharedResourceStatus defines the observed state of SandboxSharedResource",
+				Properties: map[string]spec.Schema{
+					"conditions": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Conditions holds the SourceReady condition, which is false when the resource cannot be copied into sandboxes",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("./pkg/apis/operators/v1alpha1.SandboxCondition"),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"./pkg/apis/operators/v1alpha1.SandboxCondition"},
+	}
+}
+
 func schema_pkg_apis_operators_v1alpha1_SandboxSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
@@ -77,9 +469,109 @@ func schema_pkg_apis_operators_v1alpha1_SandboxStatus(ref common.ReferenceCallba
 		Schema: spec.Schema{
 			SchemaProps: spec.SchemaProps{
 				Description: "SandboxStatus defines the observed state of Sandbox",
-				Properties:  map[string]spec.Schema{},
+				Properties: map[string]spec.Schema{
+					"phase": {
+						SchemaProps: spec.SchemaProps{
+							Type:   []string{"string"},
+							Format: "",
+						},
+					},
+					"observedGeneration": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ObservedGeneration is the generation of the Sandbox that was last provisioned",
+							Type:        []string{"integer"},
+							Format:      "int64",
+						},
+					},
+					"teardownStep": {
+						SchemaProps: spec.SchemaProps{
+							Description: "TeardownStep is the teardown step in progress while the Sandbox is terminating",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"stuckResources": {
+						SchemaProps: spec.SchemaProps{
+							Description: "StuckResources lists the resources that are preventing the namespace from terminating",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Type:   []string{"string"},
+										Format: "",
+									},
+								},
+							},
+						},
+					},
+					"quotaUsage": {
+						SchemaProps: spec.SchemaProps{
+							Description: "QuotaUsage is the percentage of each resource of the ResourceQuota that is used",
+							Type:        []string{"object"},
+							AdditionalProperties: &spec.SchemaOrBool{
+								Allows: true,
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Type:   []string{"integer"},
+										Format: "int64",
+									},
+								},
+							},
+						},
+					},
+					"conditions": {
+						SchemaProps: spec.SchemaProps{
+							Type: []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("./pkg/apis/operators/v1alpha1.SandboxCondition"),
+									},
+								},
+							},
+						},
+					},
+					"cost": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("./pkg/apis/operators/v1alpha1.SandboxCost"),
+						},
+					},
+					"budget": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("./pkg/apis/operators/v1alpha1.SandboxBudget"),
+						},
+					},
+					"size": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Size is the size of the ResourceQuota that is applied, which lags behind the size in the spec while a resize is waiting for approval",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"resizeHistory": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ResizeHistory lists the most recent resizes of the Sandbox",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("./pkg/apis/operators/v1alpha1.SandboxResize"),
+									},
+								},
+							},
+						},
+					},
+					"deletedBy": {
+						SchemaProps: spec.SchemaProps{
+							Description: "DeletedBy is the user that deleted the Sandbox, as recorded by the webhook",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+				},
 			},
 		},
-		Dependencies: []string{},
+		Dependencies: []string{
+			"./pkg/apis/operators/v1alpha1.SandboxBudget", "./pkg/apis/operators/v1alpha1.SandboxCondition", "./pkg/apis/operators/v1alpha1.SandboxCost", "./pkg/apis/operators/v1alpha1.SandboxResize"},
 	}
 }
@@ -0,0 +1,98 @@
+// Command kubectl-sandbox is a kubectl plugin to manage Sandboxes.
+// Install it on the PATH and run it as kubectl sandbox.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/plexsystems/sandbox-operator/apis"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const usage = `Manage Sandboxes in the current cluster.
+
+Usage:
+  kubectl sandbox create NAME [--owner OWNER]... [--size SIZE] [--budget BUDGET] [--bundle FILE]
+  kubectl sandbox list [--mine]
+  kubectl sandbox describe NAME
+  kubectl sandbox add-owner NAME OWNER...
+  kubectl sandbox remove-owner NAME OWNER...
+  kubectl sandbox resize NAME SIZE
+  kubectl sandbox hibernate NAME
+  kubectl sandbox wake NAME
+  kubectl sandbox delete NAME
+  kubectl sandbox kubeconfig NAME
+
+Global flags:
+  --kubeconfig PATH  Path to the kubeconfig file, defaults to $KUBECONFIG or ~/.kube/config
+  --context NAME     Kubeconfig context to use, defaults to the current context
+`
+
+func main() {
+	flags := flag.NewFlagSet("kubectl-sandbox", flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+
+	kubeconfig := flags.String("kubeconfig", "", "path to the kubeconfig file")
+	kubecontext := flags.String("context", "", "kubeconfig context to use")
+	flags.Parse(os.Args[1:])
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = *kubeconfig
+	overrides := clientcmd.ConfigOverrides{CurrentContext: *kubecontext}
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &overrides)
+
+	p, err := newPlugin(clientConfig, loadingRules, *kubecontext)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := p.run(context.Background(), flags.Arg(0), flags.Args()[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// newPlugin creates a plugin that works against the cluster of the kubeconfig
+func newPlugin(clientConfig clientcmd.ClientConfig, configAccess clientcmd.ConfigAccess, contextName string) (*plugin, error) {
+	config, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("load kubeconfig: %w", err)
+	}
+
+	s := runtime.NewScheme()
+	if err := scheme.AddToScheme(s); err != nil {
+		return nil, fmt.Errorf("add scheme: %w", err)
+	}
+
+	if err := apis.AddToScheme(s); err != nil {
+		return nil, fmt.Errorf("add crd scheme: %w", err)
+	}
+
+	c, err := client.New(config, client.Options{Scheme: s})
+	if err != nil {
+		return nil, fmt.Errorf("new client: %w", err)
+	}
+
+	p := plugin{
+		client:       c,
+		clientConfig: clientConfig,
+		configAccess: configAccess,
+		contextName:  contextName,
+		out:          os.Stdout,
+	}
+
+	return &p, nil
+}
@@ -0,0 +1,463 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	operatorsv1alpha1 "github.com/plexsystems/sandbox-operator/apis/operators/v1alpha1"
+	"github.com/plexsystems/sandbox-operator/controller"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// userClaims are the token claims that name the user, in order of preference
+var userClaims = []string{"upn", "email", "preferred_username", "unique_name"}
+
+// plugin runs the commands of the kubectl plugin
+type plugin struct {
+	client       client.Client
+	clientConfig clientcmd.ClientConfig
+	configAccess clientcmd.ConfigAccess
+	contextName  string
+	out          io.Writer
+}
+
+// run runs the command with its arguments
+func (p *plugin) run(ctx context.Context, command string, args []string) error {
+	switch command {
+	case "create":
+		return p.create(ctx, args)
+	case "list":
+		return p.list(ctx, args)
+	case "describe":
+		return p.describe(ctx, args)
+	case "add-owner":
+		return p.addOwner(ctx, args)
+	case "remove-owner":
+		return p.removeOwner(ctx, args)
+	case "resize":
+		return p.resize(ctx, args)
+	case "hibernate":
+		return p.hibernate(ctx, args)
+	case "wake":
+		return p.wake(ctx, args)
+	case "delete":
+		return p.delete(ctx, args)
+	case "kubeconfig":
+		return p.kubeconfig(args)
+	}
+
+	return fmt.Errorf("unknown command %q, see kubectl sandbox --help", command)
+}
+
+// ownerFlags collects the values of a repeated --owner flag
+type ownerFlags []string
+
+func (o *ownerFlags) String() string {
+	return strings.Join(*o, ",")
+}
+
+func (o *ownerFlags) Set(value string) error {
+	*o = append(*o, value)
+	return nil
+}
+
+func (p *plugin) create(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("create", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+
+	var owners ownerFlags
+	flags.Var(&owners, "owner", "owner of the sandbox, defaults to the current user")
+	size := flags.String("size", "small", "size of the sandbox")
+	budget := flags.String("budget", "", "most the sandbox may cost over its lifetime")
+	bundle := flags.String("bundle", "", "file of manifests to apply into the sandbox once it is created")
+
+	name, err := parseName(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if !controller.ContainsString(controller.Sizes, *size) {
+		return fmt.Errorf("size must be one of %s: %s", strings.Join(controller.Sizes, ", "), *size)
+	}
+
+	if *budget != "" {
+		if _, err := controller.ParseBudget(*budget); err != nil {
+			return err
+		}
+	}
+
+	if len(owners) == 0 {
+		user, err := p.getCurrentUser()
+		if err != nil {
+			return fmt.Errorf("get current user: %w", err)
+		}
+
+		owners = ownerFlags{user}
+	}
+
+	sandbox := operatorsv1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: operatorsv1alpha1.SandboxSpec{
+			Owners: owners,
+			Size:   *size,
+			Budget: *budget,
+		},
+	}
+
+	if *bundle != "" {
+		return p.importBundle(ctx, sandbox, *bundle)
+	}
+
+	if err := p.client.Create(ctx, &sandbox); err != nil {
+		return fmt.Errorf("create sandbox: %w", err)
+	}
+
+	fmt.Fprintf(p.out, "sandbox/%s created\n", name)
+	return nil
+}
+
+// importBundle creates the Sandbox and applies the manifests of the file into
+// its namespace as the current user
+func (p *plugin) importBundle(ctx context.Context, sandbox operatorsv1alpha1.Sandbox, path string) error {
+	bundle, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open bundle: %w", err)
+	}
+	defer bundle.Close()
+
+	if err := controller.ImportBundle(ctx, p.client, sandbox, bundle); err != nil {
+		return fmt.Errorf("import bundle: %w", err)
+	}
+
+	fmt.Fprintf(p.out, "sandbox/%s created from %s\n", sandbox.Name, path)
+	return nil
+}
+
+func (p *plugin) list(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	mine := flags.Bool("mine", false, "only list the sandboxes owned by the current user")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("parse flags: %w", err)
+	}
+
+	var user string
+	if *mine {
+		var err error
+		user, err = p.getCurrentUser()
+		if err != nil {
+			return fmt.Errorf("get current user: %w", err)
+		}
+	}
+
+	var sandboxes operatorsv1alpha1.SandboxList
+	if err := p.client.List(ctx, &sandboxes); err != nil {
+		return fmt.Errorf("list sandboxes: %w", err)
+	}
+
+	w := tabwriter.NewWriter(p.out, 0, 8, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSIZE\tPHASE\tOWNERS\tAGE")
+	for _, sandbox := range sandboxes.Items {
+		if *mine && !controller.ContainsString(sandbox.Spec.Owners, user) {
+			continue
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", sandbox.Name, controller.GetAppliedSize(sandbox), sandbox.Status.Phase, strings.Join(sandbox.Spec.Owners, ","), getAge(sandbox.CreationTimestamp))
+	}
+
+	return w.Flush()
+}
+
+func (p *plugin) describe(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected the name of a sandbox")
+	}
+
+	var sandbox operatorsv1alpha1.Sandbox
+	if err := p.client.Get(ctx, types.NamespacedName{Name: args[0]}, &sandbox); err != nil {
+		return fmt.Errorf("get sandbox: %w", err)
+	}
+
+	w := tabwriter.NewWriter(p.out, 0, 8, 1, ' ', 0)
+	fmt.Fprintf(w, "Name:\t%s\n", sandbox.Name)
+	fmt.Fprintf(w, "Namespace:\tsandbox-%s\n", sandbox.Name)
+	fmt.Fprintf(w, "Owners:\t%s\n", strings.Join(sandbox.Spec.Owners, ", "))
+	fmt.Fprintf(w, "Size:\t%s\n", controller.GetAppliedSize(sandbox))
+	fmt.Fprintf(w, "Phase:\t%s\n", sandbox.Status.Phase)
+	fmt.Fprintf(w, "Age:\t%s\n", getAge(sandbox.CreationTimestamp))
+
+	if sandbox.Spec.Budget != "" {
+		fmt.Fprintf(w, "Budget:\t%s\n", sandbox.Spec.Budget)
+	}
+
+	if sandbox.Status.Cost != nil {
+		fmt.Fprintf(w, "Cost:\t%s reserved, %s requested\n", sandbox.Status.Cost.Reserved, sandbox.Status.Cost.Requested)
+	}
+
+	if len(sandbox.Status.Conditions) > 0 {
+		fmt.Fprintln(w, "Conditions:")
+		for _, condition := range sandbox.Status.Conditions {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", condition.Type, condition.Status, condition.Reason, condition.Message)
+		}
+	}
+
+	if len(sandbox.Status.ResizeHistory) > 0 {
+		fmt.Fprintln(w, "Resizes:")
+		for _, resize := range sandbox.Status.ResizeHistory {
+			fmt.Fprintf(w, "  %s -> %s\t%s\t%s\n", resize.FromSize, resize.ToSize, resize.Decision, resize.Time.Format(time.RFC3339))
+		}
+	}
+
+	return w.Flush()
+}
+
+func (p *plugin) addOwner(ctx context.Context, args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("expected the name of a sandbox and the owners to add")
+	}
+
+	return p.update(ctx, args[0], func(sandbox *operatorsv1alpha1.Sandbox) error {
+		for _, owner := range args[1:] {
+			if !controller.ContainsString(sandbox.Spec.Owners, owner) {
+				sandbox.Spec.Owners = append(sandbox.Spec.Owners, owner)
+			}
+		}
+
+		return nil
+	})
+}
+
+func (p *plugin) removeOwner(ctx context.Context, args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("expected the name of a sandbox and the owners to remove")
+	}
+
+	return p.update(ctx, args[0], func(sandbox *operatorsv1alpha1.Sandbox) error {
+		var owners []string
+		for _, owner := range sandbox.Spec.Owners {
+			if !controller.ContainsString(args[1:], owner) {
+				owners = append(owners, owner)
+			}
+		}
+
+		if len(owners) == 0 {
+			return fmt.Errorf("a sandbox must have at least one owner")
+		}
+
+		sandbox.Spec.Owners = owners
+		return nil
+	})
+}
+
+func (p *plugin) resize(ctx context.Context, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("expected the name of a sandbox and its new size")
+	}
+
+	if !controller.ContainsString(controller.Sizes, args[1]) {
+		return fmt.Errorf("size must be one of %s: %s", strings.Join(controller.Sizes, ", "), args[1])
+	}
+
+	return p.update(ctx, args[0], func(sandbox *operatorsv1alpha1.Sandbox) error {
+		sandbox.Spec.Size = args[1]
+		return nil
+	})
+}
+
+func (p *plugin) hibernate(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected the name of a sandbox")
+	}
+
+	return p.update(ctx, args[0], func(sandbox *operatorsv1alpha1.Sandbox) error {
+		if sandbox.Annotations == nil {
+			sandbox.Annotations = make(map[string]string)
+		}
+
+		sandbox.Annotations[controller.HibernateAnnotation] = "true"
+		return nil
+	})
+}
+
+func (p *plugin) wake(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected the name of a sandbox")
+	}
+
+	return p.update(ctx, args[0], func(sandbox *operatorsv1alpha1.Sandbox) error {
+		delete(sandbox.Annotations, controller.HibernateAnnotation)
+		return nil
+	})
+}
+
+func (p *plugin) delete(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected the name of a sandbox")
+	}
+
+	sandbox := operatorsv1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: args[0],
+		},
+	}
+
+	if err := p.client.Delete(ctx, &sandbox); err != nil {
+		return fmt.Errorf("delete sandbox: %w", err)
+	}
+
+	fmt.Fprintf(p.out, "sandbox/%s deleted\n", args[0])
+	return nil
+}
+
+// kubeconfig adds a context for the namespace of the Sandbox to the
+// kubeconfig, using the cluster and user of the current context, and
+// switches to it
+func (p *plugin) kubeconfig(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected the name of a sandbox")
+	}
+
+	config, err := p.clientConfig.RawConfig()
+	if err != nil {
+		return fmt.Errorf("load kubeconfig: %w", err)
+	}
+
+	current, ok := config.Contexts[p.getContextName(config.CurrentContext)]
+	if !ok {
+		return fmt.Errorf("context %s not found", p.getContextName(config.CurrentContext))
+	}
+
+	name := "sandbox-" + args[0]
+	sandboxContext := *current
+	sandboxContext.Namespace = name
+
+	config.Contexts[name] = &sandboxContext
+	config.CurrentContext = name
+
+	if err := clientcmd.ModifyConfig(p.configAccess, config, true); err != nil {
+		return fmt.Errorf("modify kubeconfig: %w", err)
+	}
+
+	fmt.Fprintf(p.out, "Switched to context %q\n", name)
+	return nil
+}
+
+// update applies the change to the Sandbox, retrying when the Sandbox was
+// changed in the meantime
+func (p *plugin) update(ctx context.Context, name string, change func(*operatorsv1alpha1.Sandbox) error) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var sandbox operatorsv1alpha1.Sandbox
+		if err := p.client.Get(ctx, types.NamespacedName{Name: name}, &sandbox); err != nil {
+			return fmt.Errorf("get sandbox: %w", err)
+		}
+
+		if err := change(&sandbox); err != nil {
+			return err
+		}
+
+		return p.client.Update(ctx, &sandbox)
+	})
+	if err != nil {
+		return fmt.Errorf("update sandbox: %w", err)
+	}
+
+	fmt.Fprintf(p.out, "sandbox/%s updated\n", name)
+	return nil
+}
+
+// getCurrentUser returns the user named in the claims of the token the
+// kubeconfig authenticates with. The name of the kubeconfig user is not used,
+// as it is rarely the identity the cluster knows the user by.
+func (p *plugin) getCurrentUser() (string, error) {
+	config, err := p.clientConfig.ClientConfig()
+	if err != nil {
+		return "", fmt.Errorf("load kubeconfig: %w", err)
+	}
+
+	tokens := []string{config.BearerToken}
+	if config.AuthProvider != nil {
+		tokens = append(tokens, config.AuthProvider.Config["id-token"], config.AuthProvider.Config["access-token"])
+	}
+
+	for _, token := range tokens {
+		if user := getTokenUser(token); user != "" {
+			return user, nil
+		}
+	}
+
+	return "", fmt.Errorf("the current user could not be read from the kubeconfig credentials, use --owner")
+}
+
+// getTokenUser returns the user named in the claims of a JWT, or an empty
+// string when the token is not a JWT or names no user. The token is not
+// verified, as it is only used to default the owner to the caller.
+func getTokenUser(token string) string {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return ""
+	}
+
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return ""
+	}
+
+	for _, claim := range userClaims {
+		if user, ok := claims[claim].(string); ok && user != "" {
+			return user
+		}
+	}
+
+	return ""
+}
+
+// getContextName returns the context selected with --context, or the current context
+func (p *plugin) getContextName(currentContext string) string {
+	if p.contextName != "" {
+		return p.contextName
+	}
+
+	return currentContext
+}
+
+func parseName(flags *flag.FlagSet, args []string) (string, error) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return "", fmt.Errorf("expected the name of a sandbox")
+	}
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return "", fmt.Errorf("parse flags: %w", err)
+	}
+
+	return args[0], nil
+}
+
+func getAge(created metav1.Time) string {
+	if created.IsZero() {
+		return "<unknown>"
+	}
+
+	return duration.HumanDuration(time.Since(created.Time))
+}
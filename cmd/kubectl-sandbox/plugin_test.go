@@ -0,0 +1,184 @@
+// +build !integration
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/plexsystems/sandbox-operator/apis"
+	operatorsv1alpha1 "github.com/plexsystems/sandbox-operator/apis/operators/v1alpha1"
+	"github.com/plexsystems/sandbox-operator/controller"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPlugin_Commands_ManageSandbox(t *testing.T) {
+	ctx := context.TODO()
+
+	s := scheme.Scheme
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatalf("add scheme: %v", err)
+	}
+
+	var out bytes.Buffer
+	p := plugin{
+		client:       fake.NewFakeClientWithScheme(s),
+		clientConfig: getClientConfig(),
+		out:          &out,
+	}
+
+	commands := [][]string{
+		{"create", "test", "--size", "small"},
+		{"add-owner", "test", "bar@bar.com"},
+		{"resize", "test", "large"},
+		{"hibernate", "test"},
+	}
+
+	for _, command := range commands {
+		if err := p.run(ctx, command[0], command[1:]); err != nil {
+			t.Fatalf("run %s: %v", command[0], err)
+		}
+	}
+
+	var sandbox operatorsv1alpha1.Sandbox
+	if err := p.client.Get(ctx, types.NamespacedName{Name: "test"}, &sandbox); err != nil {
+		t.Fatalf("get sandbox: %v", err)
+	}
+
+	if !reflect.DeepEqual(sandbox.Spec.Owners, []string{"foo@bar.com", "bar@bar.com"}) {
+		t.Errorf("expected the current user and the added owner but found %v", sandbox.Spec.Owners)
+	}
+
+	if sandbox.Spec.Size != "large" {
+		t.Errorf("expected sandbox to be resized to large but was %s", sandbox.Spec.Size)
+	}
+
+	if sandbox.Annotations[controller.HibernateAnnotation] != "true" {
+		t.Errorf("expected sandbox to be hibernated but found annotations %v", sandbox.Annotations)
+	}
+
+	if err := p.run(ctx, "wake", []string{"test"}); err != nil {
+		t.Fatalf("wake: %v", err)
+	}
+
+	if err := p.client.Get(ctx, types.NamespacedName{Name: "test"}, &sandbox); err != nil {
+		t.Fatalf("get sandbox: %v", err)
+	}
+
+	if _, ok := sandbox.Annotations[controller.HibernateAnnotation]; ok {
+		t.Errorf("expected sandbox to be woken up but found annotations %v", sandbox.Annotations)
+	}
+
+	if err := p.run(ctx, "remove-owner", []string{"test", "foo@bar.com", "bar@bar.com"}); err == nil {
+		t.Errorf("expected removing every owner to fail")
+	}
+
+	out.Reset()
+	if err := p.run(ctx, "list", []string{"--mine"}); err != nil {
+		t.Fatalf("list: %v", err)
+	}
+
+	if !strings.Contains(out.String(), "test") {
+		t.Errorf("expected the sandbox of the current user to be listed but got:\n%s", out.String())
+	}
+
+	if err := p.run(ctx, "delete", []string{"test"}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+}
+
+func TestPlugin_Create_WithoutUserInToken_RequiresOwner(t *testing.T) {
+	s := scheme.Scheme
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatalf("add scheme: %v", err)
+	}
+
+	config := getRawConfig()
+	config.AuthInfos["cluster-user"].Token = "opaque-token"
+
+	p := plugin{
+		client:       fake.NewFakeClientWithScheme(s),
+		clientConfig: clientcmd.NewDefaultClientConfig(*config, &clientcmd.ConfigOverrides{}),
+		out:          ioutil.Discard,
+	}
+
+	if err := p.run(context.TODO(), "create", []string{"test"}); err == nil {
+		t.Errorf("expected create without --owner to fail when the user is unknown")
+	}
+
+	if err := p.run(context.TODO(), "create", []string{"test", "--owner", "foo@bar.com"}); err != nil {
+		t.Errorf("expected create with --owner to succeed: %v", err)
+	}
+}
+
+func TestPlugin_Kubeconfig_SwitchesToSandboxNamespace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubeconfig")
+	if err != nil {
+		t.Fatalf("create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config")
+	if err := clientcmd.WriteToFile(*getRawConfig(), path); err != nil {
+		t.Fatalf("write kubeconfig: %v", err)
+	}
+
+	loadingRules := clientcmd.ClientConfigLoadingRules{ExplicitPath: path}
+	p := plugin{
+		clientConfig: clientcmd.NewNonInteractiveDeferredLoadingClientConfig(&loadingRules, &clientcmd.ConfigOverrides{}),
+		configAccess: &loadingRules,
+		out:          ioutil.Discard,
+	}
+
+	if err := p.run(context.TODO(), "kubeconfig", []string{"test"}); err != nil {
+		t.Fatalf("kubeconfig: %v", err)
+	}
+
+	config, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		t.Fatalf("load kubeconfig: %v", err)
+	}
+
+	if config.CurrentContext != "sandbox-test" {
+		t.Fatalf("expected current context to be sandbox-test but was %s", config.CurrentContext)
+	}
+
+	sandboxContext := config.Contexts["sandbox-test"]
+	if sandboxContext.Namespace != "sandbox-test" || sandboxContext.AuthInfo != "cluster-user" {
+		t.Errorf("expected the sandbox namespace with the current user but found %+v", sandboxContext)
+	}
+}
+
+func getRawConfig() *clientcmdapi.Config {
+	config := clientcmdapi.NewConfig()
+	config.Clusters["cluster"] = &clientcmdapi.Cluster{Server: "https://localhost:6443"}
+	config.AuthInfos["cluster-user"] = &clientcmdapi.AuthInfo{Token: getToken("foo@bar.com")}
+	config.Contexts["cluster"] = &clientcmdapi.Context{Cluster: "cluster", AuthInfo: "cluster-user"}
+	config.CurrentContext = "cluster"
+
+	return config
+}
+
+func getClientConfig() clientcmd.ClientConfig {
+	return clientcmd.NewDefaultClientConfig(*getRawConfig(), &clientcmd.ConfigOverrides{})
+}
+
+// getToken returns an unsigned JWT naming the user in its upn claim
+func getToken(user string) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	claims := base64.RawURLEncoding.EncodeToString([]byte(`{"upn":"` + user + `"}`))
+
+	return header + "." + claims + ".signature"
+}
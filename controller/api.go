@@ -0,0 +1,453 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	operatorsv1alpha1 "github.com/plexsystems/sandbox-operator/apis/operators/v1alpha1"
+
+	"github.com/go-openapi/spec"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/transport"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// apiPrefix is the path the Sandboxes are served under
+const apiPrefix = "/api/v1/sandboxes"
+
+// Headers set by an authenticating proxy in front of the API
+const (
+	remoteUserHeader  = "X-Remote-User"
+	remoteGroupHeader = "X-Remote-Group"
+)
+
+// systemPrefix is the prefix of the users and groups of Kubernetes itself,
+// which the authenticating proxy cannot act as
+const systemPrefix = "system:"
+
+// apiHandler serves a REST API to manage Sandboxes. Callers are authenticated
+// and every request is made to the API server impersonating the caller, so
+// the RBAC of the caller applies to what they can do.
+type apiHandler struct {
+	// authClient reviews the bearer tokens of callers
+	authClient client.Client
+
+	// trustedHeaders accepts the user and groups set in headers by an
+	// authenticating proxy instead of a bearer token
+	trustedHeaders bool
+
+	// allowedNames are the common names the client certificate of the proxy
+	// may have, any name is allowed when empty
+	allowedNames []string
+
+	// newClient returns a client that impersonates the user
+	newClient func(user authenticationv1.UserInfo) (client.Client, error)
+}
+
+// NewAPIHandler returns the handler of the Sandbox REST API. Requests are made
+// with the config impersonating the authenticated caller. Trusted headers are
+// only accepted from a proxy that presents a client certificate verified by
+// the server, with one of the allowed names when any are given.
+func NewAPIHandler(config *rest.Config, scheme *runtime.Scheme, mapper meta.RESTMapper, authClient client.Client, trustedHeaders bool, allowedNames []string) (http.Handler, error) {
+	// The transport is shared by the clients of every caller, so that their
+	// connections to the API server are reused
+	sharedTransport, err := rest.TransportFor(config)
+	if err != nil {
+		return nil, fmt.Errorf("new transport: %w", err)
+	}
+
+	handler := apiHandler{
+		authClient:     authClient,
+		trustedHeaders: trustedHeaders,
+		allowedNames:   allowedNames,
+		newClient: func(user authenticationv1.UserInfo) (client.Client, error) {
+			extra := make(map[string][]string, len(user.Extra))
+			for key, value := range user.Extra {
+				extra[key] = value
+			}
+
+			// The UID of the caller is not impersonated, as neither client-go
+			// nor the API servers of this version support impersonating it
+			impersonation := transport.ImpersonationConfig{
+				UserName: user.Username,
+				Groups:   user.Groups,
+				Extra:    extra,
+			}
+
+			impersonated := rest.Config{
+				Host:      config.Host,
+				APIPath:   config.APIPath,
+				QPS:       config.QPS,
+				Burst:     config.Burst,
+				Timeout:   config.Timeout,
+				Transport: transport.NewImpersonatingRoundTripper(impersonation, sharedTransport),
+			}
+
+			return client.New(&impersonated, client.Options{Scheme: scheme, Mapper: mapper})
+		},
+	}
+
+	return &handler, nil
+}
+
+// ServeHTTP routes the request to the Sandbox it is about
+func (a *apiHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/openapi.json" && req.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, getOpenAPISpec())
+		return
+	}
+
+	if req.URL.Path != apiPrefix && !strings.HasPrefix(req.URL.Path, apiPrefix+"/") {
+		http.NotFound(w, req)
+		return
+	}
+
+	user, err := a.authenticate(req)
+	if err != nil {
+		writeStatus(w, errors.NewUnauthorized(err.Error()))
+		return
+	}
+
+	c, err := a.newClient(user)
+	if err != nil {
+		log.Error(err, "New impersonating client", "user", user.Username)
+		writeStatus(w, errors.NewInternalError(fmt.Errorf("new client")))
+		return
+	}
+
+	ctx := req.Context()
+	name := strings.Trim(strings.TrimPrefix(req.URL.Path, apiPrefix), "/")
+	switch {
+	case name == "" && req.Method == http.MethodGet:
+		var sandboxes operatorsv1alpha1.SandboxList
+		err = c.List(ctx, &sandboxes)
+		respond(w, http.StatusOK, &sandboxes, err)
+
+	case name == "" && req.Method == http.MethodPost:
+		var sandbox operatorsv1alpha1.Sandbox
+		if err := json.NewDecoder(req.Body).Decode(&sandbox); err != nil {
+			writeStatus(w, errors.NewBadRequest(fmt.Sprintf("decode sandbox: %v", err)))
+			return
+		}
+
+		created := operatorsv1alpha1.Sandbox{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        sandbox.Name,
+				Labels:      sandbox.Labels,
+				Annotations: sandbox.Annotations,
+			},
+			Spec: sandbox.Spec,
+		}
+
+		err = c.Create(ctx, &created)
+		respond(w, http.StatusCreated, &created, err)
+
+	case name != "" && req.Method == http.MethodGet:
+		var sandbox operatorsv1alpha1.Sandbox
+		err = c.Get(ctx, types.NamespacedName{Name: name}, &sandbox)
+		respond(w, http.StatusOK, &sandbox, err)
+
+	case name != "" && req.Method == http.MethodPut:
+		var sandbox operatorsv1alpha1.Sandbox
+		if err := json.NewDecoder(req.Body).Decode(&sandbox); err != nil {
+			writeStatus(w, errors.NewBadRequest(fmt.Sprintf("decode sandbox: %v", err)))
+			return
+		}
+
+		updated, err := updateSandboxSpec(ctx, c, name, sandbox)
+		respond(w, http.StatusOK, updated, err)
+
+	case name != "" && req.Method == http.MethodDelete:
+		sandbox := operatorsv1alpha1.Sandbox{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+			},
+		}
+
+		err = c.Delete(ctx, &sandbox)
+		respond(w, http.StatusOK, &metav1.Status{Status: metav1.StatusSuccess}, err)
+
+	default:
+		writeStatus(w, errors.NewMethodNotSupported(operatorsv1alpha1.SchemeGroupVersion.WithResource("sandboxes").GroupResource(), req.Method))
+	}
+}
+
+// authenticate returns the user set by a trusted proxy, or the user of the
+// bearer token of the request
+func (a *apiHandler) authenticate(req *http.Request) (authenticationv1.UserInfo, error) {
+	if a.trustedHeaders && req.Header.Get(remoteUserHeader) != "" {
+		if err := a.verifyProxy(req); err != nil {
+			return authenticationv1.UserInfo{}, err
+		}
+
+		user := authenticationv1.UserInfo{
+			Username: req.Header.Get(remoteUserHeader),
+			Groups:   req.Header[http.CanonicalHeaderKey(remoteGroupHeader)],
+		}
+
+		if strings.HasPrefix(user.Username, systemPrefix) {
+			return authenticationv1.UserInfo{}, fmt.Errorf("the proxy cannot act as the user %s", user.Username)
+		}
+
+		for _, group := range user.Groups {
+			if strings.HasPrefix(group, systemPrefix) {
+				return authenticationv1.UserInfo{}, fmt.Errorf("the proxy cannot act as a member of %s", group)
+			}
+		}
+
+		return user, nil
+	}
+
+	return reviewToken(req, a.authClient)
+}
+
+// verifyProxy checks that the request was made by the authenticating proxy,
+// which presents a client certificate signed by the client CA of the server
+func (a *apiHandler) verifyProxy(req *http.Request) error {
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 || len(req.TLS.VerifiedChains[0]) == 0 {
+		return fmt.Errorf("a verified client certificate is required to set %s", remoteUserHeader)
+	}
+
+	name := req.TLS.VerifiedChains[0][0].Subject.CommonName
+	if len(a.allowedNames) > 0 && !ContainsString(a.allowedNames, name) {
+		return fmt.Errorf("the client certificate %s is not allowed to set %s", name, remoteUserHeader)
+	}
+
+	return nil
+}
+
+// reviewToken returns the user of the bearer token of the request
+func reviewToken(req *http.Request, authClient client.Client) (authenticationv1.UserInfo, error) {
+	token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if token == "" || token == req.Header.Get("Authorization") {
+		return authenticationv1.UserInfo{}, fmt.Errorf("a bearer token is required")
+	}
+
+	review := authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{
+			Token: token,
+		},
+	}
+
+	if err := authClient.Create(req.Context(), &review); err != nil {
+		log.Error(err, "Review token")
+		return authenticationv1.UserInfo{}, fmt.Errorf("the token could not be reviewed")
+	}
+
+	if !review.Status.Authenticated {
+		return authenticationv1.UserInfo{}, fmt.Errorf("the token is not valid")
+	}
+
+	return review.Status.User, nil
+}
+
+// authorizeNonResourceURL authenticates the caller with its bearer token and
+// checks that the caller may get the path of the request as a non-resource
+// URL, the way the API server authorizes paths such as /metrics. It returns
+// the HTTP status to respond with when the caller is not allowed.
+func authorizeNonResourceURL(req *http.Request, authClient client.Client) (int, error) {
+	user, err := reviewToken(req, authClient)
+	if err != nil {
+		return http.StatusUnauthorized, err
+	}
+
+	extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+	for key, value := range user.Extra {
+		extra[key] = authorizationv1.ExtraValue(value)
+	}
+
+	review := authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   user.Username,
+			Groups: user.Groups,
+			UID:    user.UID,
+			Extra:  extra,
+			NonResourceAttributes: &authorizationv1.NonResourceAttributes{
+				Path: req.URL.Path,
+				Verb: "get",
+			},
+		},
+	}
+
+	if err := authClient.Create(req.Context(), &review); err != nil {
+		log.Error(err, "Review access", "user", user.Username, "path", req.URL.Path)
+		return http.StatusInternalServerError, fmt.Errorf("the access could not be reviewed")
+	}
+
+	if !review.Status.Allowed {
+		return http.StatusForbidden, fmt.Errorf("%s is not allowed to get %s", user.Username, req.URL.Path)
+	}
+
+	return http.StatusOK, nil
+}
+
+// updateSandboxSpec replaces the spec of the Sandbox. The resource version is
+// checked when it is set, so that callers do not overwrite changes they have
+// not seen.
+func updateSandboxSpec(ctx context.Context, c client.Client, name string, sandbox operatorsv1alpha1.Sandbox) (*operatorsv1alpha1.Sandbox, error) {
+	var updated operatorsv1alpha1.Sandbox
+	if err := c.Get(ctx, types.NamespacedName{Name: name}, &updated); err != nil {
+		return nil, err
+	}
+
+	if sandbox.ResourceVersion != "" {
+		updated.ResourceVersion = sandbox.ResourceVersion
+	}
+
+	updated.Spec = sandbox.Spec
+	if err := c.Update(ctx, &updated); err != nil {
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+// respond writes the object, or the error returned by the API server
+func respond(w http.ResponseWriter, status int, object interface{}, err error) {
+	if err != nil {
+		writeStatus(w, err)
+		return
+	}
+
+	writeJSON(w, status, object)
+}
+
+// writeStatus writes the error as a Kubernetes Status
+func writeStatus(w http.ResponseWriter, err error) {
+	status, ok := err.(errors.APIStatus)
+	if !ok {
+		log.Error(err, "Serve API request")
+		status = errors.NewInternalError(fmt.Errorf("unexpected error"))
+	}
+
+	writeJSON(w, int(status.Status().Code), status.Status())
+}
+
+func writeJSON(w http.ResponseWriter, status int, object interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(object); err != nil {
+		log.Error(err, "Write API response")
+	}
+}
+
+// getOpenAPISpec describes the API using the OpenAPI definitions of the
+// v1alpha1 types
+func getOpenAPISpec() *spec.Swagger {
+	ref := func(name string) spec.Ref {
+		return spec.MustCreateRef("#/definitions/" + getDefinitionName(name))
+	}
+
+	definitions := spec.Definitions{}
+	for name, definition := range operatorsv1alpha1.GetOpenAPIDefinitions(ref) {
+		definitions[getDefinitionName(name)] = definition.Schema
+	}
+
+	// The definitions of the apimachinery types are not generated in this
+	// repository, so they are described loosely with a link to the reference
+	definitions["v1.ObjectMeta"] = *spec.MapProperty(nil).
+		WithDescription("Standard object metadata, see https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.15/#objectmeta-v1-meta")
+	definitions["v1.LabelSelector"] = *spec.MapProperty(nil).
+		WithDescription("A label selector, see https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.15/#labelselector-v1-meta")
+	definitions["v1.Time"] = *spec.DateTimeProperty()
+
+	sandbox := spec.RefSchema("#/definitions/v1alpha1.Sandbox")
+	sandboxList := spec.ArrayProperty(sandbox)
+	definitions["v1alpha1.SandboxList"] = *new(spec.Schema).
+		Typed("object", "").
+		WithDescription("SandboxList is a list of Sandboxes").
+		SetProperty("items", *sandboxList)
+
+	response := func(description string, schema *spec.Schema) spec.Response {
+		return *spec.NewResponse().WithDescription(description).WithSchema(schema)
+	}
+
+	operation := func(id string, description string, responses map[int]spec.Response) *spec.Operation {
+		op := spec.NewOperation(id).WithDescription(description)
+		for code, r := range responses {
+			r := r
+			op.RespondsWith(code, &r)
+		}
+
+		op.RespondsWith(http.StatusUnauthorized, spec.NewResponse().WithDescription("The caller could not be authenticated"))
+		return op
+	}
+
+	name := spec.PathParam("name").Typed("string", "").WithDescription("Name of the Sandbox")
+	body := spec.BodyParam("body", sandbox).AsRequired()
+
+	listSandboxes := operation("listSandboxes", "List the Sandboxes the caller can list", map[int]spec.Response{
+		http.StatusOK: response("The Sandboxes", spec.RefSchema("#/definitions/v1alpha1.SandboxList")),
+	})
+
+	createSandbox := operation("createSandbox", "Create a Sandbox", map[int]spec.Response{
+		http.StatusCreated: response("The created Sandbox", sandbox),
+	}).AddParam(body)
+
+	getSandbox := operation("getSandbox", "Get a Sandbox", map[int]spec.Response{
+		http.StatusOK: response("The Sandbox", sandbox),
+	}).AddParam(name)
+
+	updateSandbox := operation("updateSandbox", "Replace the spec of a Sandbox", map[int]spec.Response{
+		http.StatusOK: response("The updated Sandbox", sandbox),
+	}).AddParam(name).AddParam(body)
+
+	deleteSandbox := operation("deleteSandbox", "Delete a Sandbox", map[int]spec.Response{
+		http.StatusOK: response("The Sandbox is being deleted", nil),
+	}).AddParam(name)
+
+	swagger := spec.Swagger{
+		SwaggerProps: spec.SwaggerProps{
+			Swagger:  "2.0",
+			Consumes: []string{"application/json"},
+			Produces: []string{"application/json"},
+			Info: &spec.Info{
+				InfoProps: spec.InfoProps{
+					Title:       "Sandbox API",
+					Description: "Manage Sandboxes as the authenticated caller",
+					Version:     "v1",
+				},
+			},
+			SecurityDefinitions: spec.SecurityDefinitions{
+				"bearer": spec.APIKeyAuth("Authorization", "header"),
+			},
+			Security: []map[string][]string{{"bearer": {}}},
+			Paths: &spec.Paths{
+				Paths: map[string]spec.PathItem{
+					apiPrefix: {
+						PathItemProps: spec.PathItemProps{
+							Get:  listSandboxes,
+							Post: createSandbox,
+						},
+					},
+					apiPrefix + "/{name}": {
+						PathItemProps: spec.PathItemProps{
+							Get:    getSandbox,
+							Put:    updateSandbox,
+							Delete: deleteSandbox,
+						},
+					},
+				},
+			},
+			Definitions: definitions,
+		},
+	}
+
+	return &swagger
+}
+
+// getDefinitionName returns the name of an OpenAPI definition without the
+// path of its package, such as v1alpha1.Sandbox
+func getDefinitionName(name string) string {
+	return name[strings.LastIndex(name, "/")+1:]
+}
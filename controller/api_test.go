@@ -0,0 +1,200 @@
+// +build !integration
+
+package controller
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/plexsystems/sandbox-operator/apis"
+	operatorsv1alpha1 "github.com/plexsystems/sandbox-operator/apis/operators/v1alpha1"
+
+	"github.com/go-openapi/spec"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestAPIHandler_TrustedHeaders_ImpersonatesCaller(t *testing.T) {
+	s := scheme.Scheme
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatalf("add scheme: %v", err)
+	}
+
+	fakeClient := fake.NewFakeClientWithScheme(s)
+
+	var users []authenticationv1.UserInfo
+	handler := apiHandler{
+		trustedHeaders: true,
+		allowedNames:   []string{"front-proxy"},
+		newClient: func(user authenticationv1.UserInfo) (client.Client, error) {
+			users = append(users, user)
+			return fakeClient, nil
+		},
+	}
+
+	serve := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.TLS = getVerifiedConnection("front-proxy")
+		req.Header.Set(remoteUserHeader, "foo@bar.com")
+		req.Header.Add(remoteGroupHeader, "developers")
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	if rr := serve(http.MethodPost, apiPrefix, `{"metadata":{"name":"test"},"spec":{"owners":["foo@bar.com"],"size":"small"}}`); rr.Code != http.StatusCreated {
+		t.Fatalf("expected create to return %d but got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	if rr := serve(http.MethodPut, apiPrefix+"/test", `{"spec":{"owners":["foo@bar.com"],"size":"large"}}`); rr.Code != http.StatusOK {
+		t.Fatalf("expected update to return %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	rr := serve(http.MethodGet, apiPrefix+"/test", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected get to return %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var sandbox operatorsv1alpha1.Sandbox
+	if err := json.Unmarshal(rr.Body.Bytes(), &sandbox); err != nil {
+		t.Fatalf("decode sandbox: %v", err)
+	}
+
+	if sandbox.Spec.Size != "large" {
+		t.Errorf("expected sandbox to be resized to large but was %s", sandbox.Spec.Size)
+	}
+
+	if rr := serve(http.MethodDelete, apiPrefix+"/test", ""); rr.Code != http.StatusOK {
+		t.Fatalf("expected delete to return %d but got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	if rr := serve(http.MethodGet, apiPrefix+"/test", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected get of deleted sandbox to return %d but got %d", http.StatusNotFound, rr.Code)
+	}
+
+	expected := authenticationv1.UserInfo{Username: "foo@bar.com", Groups: []string{"developers"}}
+	for _, user := range users {
+		if !reflect.DeepEqual(user, expected) {
+			t.Fatalf("expected every request to impersonate %+v but found %+v", expected, user)
+		}
+	}
+}
+
+func TestAPIHandler_WithoutCredentials_Unauthorized(t *testing.T) {
+	handler := apiHandler{
+		newClient: func(user authenticationv1.UserInfo) (client.Client, error) {
+			t.Fatalf("expected no client for an unauthenticated caller")
+			return nil, nil
+		},
+	}
+
+	// Trusted headers are ignored unless they are enabled
+	req := httptest.NewRequest(http.MethodGet, apiPrefix, nil)
+	req.Header.Set(remoteUserHeader, "foo@bar.com")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected %d but got %d", http.StatusUnauthorized, rr.Code)
+	}
+}
+
+func TestAPIHandler_UntrustedProxy_Unauthorized(t *testing.T) {
+	handler := apiHandler{
+		trustedHeaders: true,
+		allowedNames:   []string{"front-proxy"},
+		newClient: func(user authenticationv1.UserInfo) (client.Client, error) {
+			t.Fatalf("expected no client for an untrusted proxy but got one for %+v", user)
+			return nil, nil
+		},
+	}
+
+	requests := map[string]struct {
+		connection *tls.ConnectionState
+		group      string
+	}{
+		"without a client certificate": {nil, "developers"},
+		"with another certificate":     {getVerifiedConnection("someone"), "developers"},
+		"with a system group":          {getVerifiedConnection("front-proxy"), "system:masters"},
+	}
+
+	for name, request := range requests {
+		req := httptest.NewRequest(http.MethodGet, apiPrefix, nil)
+		req.TLS = request.connection
+		req.Header.Set(remoteUserHeader, "foo@bar.com")
+		req.Header.Add(remoteGroupHeader, request.group)
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected a request %s to return %d but got %d", name, http.StatusUnauthorized, rr.Code)
+		}
+	}
+}
+
+// getVerifiedConnection returns a TLS connection whose client presented a
+// verified certificate with the common name
+func getVerifiedConnection(commonName string) *tls.ConnectionState {
+	certificate := x509.Certificate{
+		Subject: pkix.Name{CommonName: commonName},
+	}
+
+	return &tls.ConnectionState{
+		VerifiedChains: [][]*x509.Certificate{{&certificate}},
+	}
+}
+
+func TestAPIHandler_OpenAPI_DescribesSandbox(t *testing.T) {
+	handler := apiHandler{}
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected %d but got %d", http.StatusOK, rr.Code)
+	}
+
+	var swagger spec.Swagger
+	if err := json.Unmarshal(rr.Body.Bytes(), &swagger); err != nil {
+		t.Fatalf("decode openapi: %v", err)
+	}
+
+	sandbox, ok := swagger.Definitions["v1alpha1.Sandbox"]
+	if !ok {
+		t.Fatalf("expected the Sandbox definition")
+	}
+
+	if _, ok := sandbox.Properties["spec"]; !ok {
+		t.Errorf("expected the Sandbox definition to describe the spec")
+	}
+
+	for name, definition := range swagger.Definitions {
+		for property, schema := range definition.Properties {
+			ref := schema.Ref.String()
+			if ref == "" && schema.Items != nil && schema.Items.Schema != nil {
+				ref = schema.Items.Schema.Ref.String()
+			}
+
+			if ref == "" {
+				continue
+			}
+
+			if _, ok := swagger.Definitions[strings.TrimPrefix(ref, "#/definitions/")]; !ok {
+				t.Errorf("expected %s.%s to refer to a definition but found %s", name, property, ref)
+			}
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	operatorsv1alpha1 "github.com/plexsystems/sandbox-operator/apis/operators/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// approvalAnnotation is set to approved or rejected by an approver
+	approvalAnnotation = "operators.plex.dev/approval"
+
+	// approvalReasonAnnotation optionally explains the approval decision
+	approvalReasonAnnotation = "operators.plex.dev/approval-reason"
+
+	// createdByAnnotation is set by the webhook to the user that created the Sandbox
+	createdByAnnotation = "operators.plex.dev/created-by"
+
+	// reviewedByAnnotation is set by the webhook to the user that approved or rejected the Sandbox
+	reviewedByAnnotation = "operators.plex.dev/reviewed-by"
+)
+
+const (
+	approvalApproved = "approved"
+	approvalRejected = "rejected"
+)
+
+// requiresCreationApproval reports whether the Sandbox must be approved before
+// it is provisioned. Sandboxes that were provisioned before approvals were
+// enabled are left alone.
+func (r *ReconcileSandbox) requiresCreationApproval(sandbox operatorsv1alpha1.Sandbox) bool {
+	if r.getOptions().CreationApprovalGroup == "" {
+		return false
+	}
+
+	switch sandbox.Status.Phase {
+	case "", operatorsv1alpha1.SandboxPhaseAwaitingApproval, operatorsv1alpha1.SandboxPhaseRejected:
+		return true
+	}
+
+	return false
+}
+
+// handleApproval holds the Sandbox until it has been approved, and deletes it
+// once the grace period of a rejection has passed. It reports whether the
+// Sandbox was approved and can be provisioned.
+func (r *ReconcileSandbox) handleApproval(ctx context.Context, sandbox *operatorsv1alpha1.Sandbox) (bool, reconcile.Result, error) {
+	status := sandbox.Status.DeepCopy()
+	options := r.getOptions()
+
+	creator := sandbox.Annotations[createdByAnnotation]
+	if creator == "" {
+		creator = "unknown"
+	}
+
+	// The reviewer is recorded by the webhook, which only lets approvers set the
+	// approval. An approval without a reviewer did not go through the webhook
+	// and could have been set by anyone, so it is ignored.
+	approval := sandbox.Annotations[approvalAnnotation]
+	reviewer := sandbox.Annotations[reviewedByAnnotation]
+	switch {
+	case approval != "" && reviewer == "":
+		previous, _ := getCondition(sandbox.Status, operatorsv1alpha1.SandboxConditionApproved)
+		if previous.Reason != "Unreviewed" {
+			r.recorder.Eventf(sandbox, corev1.EventTypeWarning, "ApprovalIgnored", "The %s annotation was not set through the admission webhook and is ignored", approvalAnnotation)
+		}
+
+		sandbox.Status.Phase = operatorsv1alpha1.SandboxPhaseAwaitingApproval
+		setCondition(&sandbox.Status, operatorsv1alpha1.SandboxConditionApproved, corev1.ConditionUnknown, "Unreviewed", fmt.Sprintf("The approval has no reviewer, waiting for approval from %s", options.CreationApprovalGroup))
+
+	case approval == approvalApproved:
+		setCondition(&sandbox.Status, operatorsv1alpha1.SandboxConditionApproved, corev1.ConditionTrue, "Approved", fmt.Sprintf("Approved by %s", reviewer))
+		r.recorder.Eventf(sandbox, corev1.EventTypeNormal, "Approved", "Sandbox requested by %s was approved by %s", creator, reviewer)
+		return true, reconcile.Result{}, nil
+
+	case approval == approvalRejected:
+		message := fmt.Sprintf("Rejected by %s", reviewer)
+		if reason := sandbox.Annotations[approvalReasonAnnotation]; reason != "" {
+			message += ": " + reason
+		}
+
+		if sandbox.Status.Phase != operatorsv1alpha1.SandboxPhaseRejected {
+			r.recorder.Eventf(sandbox, corev1.EventTypeWarning, "Rejected", "Sandbox requested by %s was rejected by %s and will be deleted in %s", creator, reviewer, options.RejectionGracePeriod)
+			r.notify(ctx, *sandbox, NotificationRejected, fmt.Sprintf("%s, the sandbox will be deleted in %s", message, options.RejectionGracePeriod))
+		}
+
+		sandbox.Status.Phase = operatorsv1alpha1.SandboxPhaseRejected
+		setCondition(&sandbox.Status, operatorsv1alpha1.SandboxConditionApproved, corev1.ConditionFalse, "Rejected", message)
+
+	default:
+		if sandbox.Status.Phase != operatorsv1alpha1.SandboxPhaseAwaitingApproval {
+			r.recorder.Eventf(sandbox, corev1.EventTypeNormal, "AwaitingApproval", "Sandbox requested by %s is waiting for approval from %s", creator, options.CreationApprovalGroup)
+		}
+
+		sandbox.Status.Phase = operatorsv1alpha1.SandboxPhaseAwaitingApproval
+		setCondition(&sandbox.Status, operatorsv1alpha1.SandboxConditionApproved, corev1.ConditionUnknown, "AwaitingApproval", fmt.Sprintf("Waiting for approval from %s", options.CreationApprovalGroup))
+	}
+
+	sandbox.Status.ObservedGeneration = sandbox.Generation
+	if !equality.Semantic.DeepEqual(*status, sandbox.Status) {
+		if err := r.client.Status().Update(ctx, sandbox); err != nil {
+			return false, reconcile.Result{}, fmt.Errorf("update status: %w", err)
+		}
+	}
+
+	if sandbox.Status.Phase != operatorsv1alpha1.SandboxPhaseRejected {
+		return false, reconcile.Result{}, nil
+	}
+
+	rejected, _ := getCondition(sandbox.Status, operatorsv1alpha1.SandboxConditionApproved)
+	if remaining := time.Until(rejected.LastTransitionTime.Add(options.RejectionGracePeriod)); remaining > 0 {
+		return false, reconcile.Result{RequeueAfter: remaining}, nil
+	}
+
+	getLogger(ctx).Info("Deleting rejected sandbox", "creator", creator, "reviewer", reviewer)
+	if err := r.client.Delete(ctx, sandbox); err != nil && !errors.IsNotFound(err) {
+		return false, reconcile.Result{}, fmt.Errorf("delete rejected Sandbox: %w", err)
+	}
+
+	return false, reconcile.Result{}, nil
+}
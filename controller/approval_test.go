@@ -0,0 +1,212 @@
+// +build !integration
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/plexsystems/sandbox-operator/apis"
+	operatorsv1alpha1 "github.com/plexsystems/sandbox-operator/apis/operators/v1alpha1"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestSandboxController_CreationApproval_WaitsUntilApproved(t *testing.T) {
+	ctx := context.TODO()
+
+	s := scheme.Scheme
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatalf("add scheme: %v", err)
+	}
+
+	recorder := record.NewFakeRecorder(20)
+	fakeClient := fake.NewFakeClientWithScheme(s)
+	r := NewReconcileSandbox(fakeClient, fakeClient, s, DefaultSubjects{}, recorder)
+	r.options.CreationApprovalGroup = "approvers"
+
+	sandbox := operatorsv1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+	}
+
+	if err := r.client.Create(ctx, &sandbox); err != nil {
+		t.Fatalf("create sandbox: %v", err)
+	}
+
+	request := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name: sandbox.Name,
+		},
+	}
+
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("reconcile sandbox: %v", err)
+	}
+
+	var foundSandbox operatorsv1alpha1.Sandbox
+	if err := r.client.Get(ctx, request.NamespacedName, &foundSandbox); err != nil {
+		t.Fatalf("get sandbox: %v", err)
+	}
+
+	if foundSandbox.Status.Phase != operatorsv1alpha1.SandboxPhaseAwaitingApproval {
+		t.Errorf("expected sandbox to be AwaitingApproval but was %s", foundSandbox.Status.Phase)
+	}
+
+	namespace := getNamespace(sandbox)
+	if err := r.client.Get(ctx, types.NamespacedName{Name: namespace.Name}, &namespace); !errors.IsNotFound(err) {
+		t.Errorf("expected namespace not to be created before approval but found: %v", err)
+	}
+
+	foundSandbox.Annotations = map[string]string{approvalAnnotation: approvalApproved}
+	if err := r.client.Update(ctx, &foundSandbox); err != nil {
+		t.Fatalf("update sandbox: %v", err)
+	}
+
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("reconcile sandbox: %v", err)
+	}
+
+	if err := r.client.Get(ctx, types.NamespacedName{Name: namespace.Name}, &namespace); !errors.IsNotFound(err) {
+		t.Errorf("expected an approval without a reviewer to be ignored but found: %v", err)
+	}
+
+	if !hasEvent(recorder, "ApprovalIgnored") {
+		t.Errorf("expected an ApprovalIgnored event but none was recorded")
+	}
+
+	if err := r.client.Get(ctx, request.NamespacedName, &foundSandbox); err != nil {
+		t.Fatalf("get sandbox: %v", err)
+	}
+
+	foundSandbox.Annotations[reviewedByAnnotation] = "admin@bar.com"
+	if err := r.client.Update(ctx, &foundSandbox); err != nil {
+		t.Fatalf("update sandbox: %v", err)
+	}
+
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("reconcile sandbox: %v", err)
+	}
+
+	if err := r.client.Get(ctx, types.NamespacedName{Name: namespace.Name}, &namespace); err != nil {
+		t.Errorf("expected namespace to be created after approval: %v", err)
+	}
+
+	if !hasEvent(recorder, "Approved") {
+		t.Errorf("expected an Approved event but none was recorded")
+	}
+}
+
+func TestSandboxController_CreationRejected_DeletesSandbox(t *testing.T) {
+	ctx := context.TODO()
+
+	s := scheme.Scheme
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatalf("add scheme: %v", err)
+	}
+
+	recorder := record.NewFakeRecorder(20)
+	fakeClient := fake.NewFakeClientWithScheme(s)
+	r := NewReconcileSandbox(fakeClient, fakeClient, s, DefaultSubjects{}, recorder)
+	r.options.CreationApprovalGroup = "approvers"
+	r.options.RejectionGracePeriod = 0
+
+	sandbox := operatorsv1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+			Annotations: map[string]string{
+				approvalAnnotation:   approvalRejected,
+				createdByAnnotation:  "foo@bar.com",
+				reviewedByAnnotation: "admin@bar.com",
+			},
+		},
+	}
+
+	if err := r.client.Create(ctx, &sandbox); err != nil {
+		t.Fatalf("create sandbox: %v", err)
+	}
+
+	request := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name: sandbox.Name,
+		},
+	}
+
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("reconcile sandbox: %v", err)
+	}
+
+	if !hasEvent(recorder, "Rejected") {
+		t.Errorf("expected a Rejected event but none was recorded")
+	}
+
+	var foundSandbox operatorsv1alpha1.Sandbox
+	err := r.client.Get(ctx, request.NamespacedName, &foundSandbox)
+	if err == nil && foundSandbox.DeletionTimestamp == nil {
+		t.Errorf("expected rejected sandbox to be deleted")
+	}
+}
+
+func TestSandboxWebhook_NonApprover_IsDenied(t *testing.T) {
+	webhook := sandboxWebhook{approverGroup: "approvers"}
+
+	oldSandbox := getSandboxJSON(t, nil)
+	sandbox := getSandboxJSON(t, map[string]string{approvalAnnotation: approvalApproved})
+
+	request := admission.Request{
+		AdmissionRequest: admissionv1beta1.AdmissionRequest{
+			Operation: admissionv1beta1.Update,
+			Object:    runtime.RawExtension{Raw: sandbox},
+			OldObject: runtime.RawExtension{Raw: oldSandbox},
+			UserInfo: authenticationv1.UserInfo{
+				Username: "owner@bar.com",
+			},
+		},
+	}
+
+	if response := webhook.Handle(context.TODO(), request); response.Allowed {
+		t.Errorf("expected non-approver to be denied")
+	}
+
+	request.UserInfo.Groups = []string{"approvers"}
+	response := webhook.Handle(context.TODO(), request)
+	if !response.Allowed {
+		t.Fatalf("expected approver to be allowed: %v", response.Result)
+	}
+
+	if len(response.Patches) == 0 {
+		t.Errorf("expected reviewer to be recorded but found no patches")
+	}
+}
+
+func getSandboxJSON(t *testing.T, annotations map[string]string) []byte {
+	sandbox := operatorsv1alpha1.Sandbox{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "operators.plex.dev/v1alpha1",
+			Kind:       "Sandbox",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test",
+			Annotations: annotations,
+		},
+	}
+
+	sandboxJSON, err := json.Marshal(sandbox)
+	if err != nil {
+		t.Fatalf("marshal sandbox: %v", err)
+	}
+
+	return sandboxJSON
+}
@@ -0,0 +1,284 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	operatorsv1alpha1 "github.com/plexsystems/sandbox-operator/apis/operators/v1alpha1"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// updatedByAnnotation is set by the webhook to the user that last changed the Sandbox
+const updatedByAnnotation = "operators.plex.dev/updated-by"
+
+// operatorActor is the actor of the actions the operator takes by itself
+const operatorActor = "sandbox-operator"
+
+// auditQueueSize is how many audit records can wait to be posted to the webhook
+const auditQueueSize = 1000
+
+// Audit actions recorded over the lifecycle of a Sandbox
+const (
+	AuditCreated      = "Created"
+	AuditResized      = "Resized"
+	AuditOwnerAdded   = "OwnerAdded"
+	AuditOwnerRemoved = "OwnerRemoved"
+	AuditHibernated   = "Hibernated"
+	AuditWoken        = "Woken"
+	AuditDeleted      = "Deleted"
+)
+
+// AuditRecord is a single entry of the audit trail of a Sandbox
+type AuditRecord struct {
+	Time      time.Time `json:"time"`
+	Action    string    `json:"action"`
+	Sandbox   string    `json:"sandbox"`
+	Namespace string    `json:"namespace"`
+
+	// Actor is the user that took the action as recorded by the webhook, or
+	// sandbox-operator for actions the operator took by itself
+	Actor string `json:"actor,omitempty"`
+
+	Owners []string `json:"owners,omitempty"`
+
+	// Subjects are the subjects the owners resolved to, or the subjects that were
+	// added or removed for owner changes
+	Subjects []string `json:"subjects,omitempty"`
+
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// AuditSink stores audit records. Sinks only ever append records.
+type AuditSink interface {
+	Write(ctx context.Context, record AuditRecord) error
+}
+
+// jsonLinesAuditSink writes each audit record as a line of JSON
+type jsonLinesAuditSink struct {
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+// NewJSONLinesAuditSink returns a sink that writes audit records as JSON lines
+func NewJSONLinesAuditSink(writer io.Writer) AuditSink {
+	return &jsonLinesAuditSink{writer: writer}
+}
+
+// NewFileAuditSink returns a sink that appends audit records as JSON lines to
+// the file at the given path, or to stdout when the path is -
+func NewFileAuditSink(path string) (AuditSink, error) {
+	if path == "-" {
+		return NewJSONLinesAuditSink(os.Stdout), nil
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+
+	return NewJSONLinesAuditSink(file), nil
+}
+
+// Write appends the record to the writer
+func (s *jsonLinesAuditSink) Write(ctx context.Context, record AuditRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("marshal audit record: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.writer.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("write audit record: %w", err)
+	}
+
+	return nil
+}
+
+// webhookAuditSink posts each audit record as JSON to an HTTP endpoint. The
+// records are queued and posted in the background, so that a slow endpoint
+// does not hold up reconciles.
+type webhookAuditSink struct {
+	*sendQueue
+
+	url    string
+	client *http.Client
+}
+
+// newWebhookAuditSink returns a sink that posts audit records to the given URL
+func newWebhookAuditSink(url string) *webhookAuditSink {
+	sink := webhookAuditSink{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	sink.sendQueue = newSendQueue(auditQueueSize, func(item interface{}) {
+		record := item.(AuditRecord)
+		if err := sink.post(context.Background(), record); err != nil {
+			log.Error(err, "Post audit record", "action", record.Action, "sandbox", record.Sandbox)
+		}
+	})
+
+	return &sink
+}
+
+// Write queues the record to be posted to the webhook
+func (s *webhookAuditSink) Write(ctx context.Context, record AuditRecord) error {
+	if !s.add(record) {
+		return fmt.Errorf("audit queue is full or closed, the record was dropped")
+	}
+
+	return nil
+}
+
+// post posts the record to the webhook
+func (s *webhookAuditSink) post(ctx context.Context, record AuditRecord) error {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("marshal audit record: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("new request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("post audit record: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("post audit record: unexpected status %s", resp.Status)
+	}
+
+	return nil
+}
+
+// multiAuditSink writes audit records to every sink
+type multiAuditSink []AuditSink
+
+// Write writes the record to every sink, returning the first error
+func (m multiAuditSink) Write(ctx context.Context, record AuditRecord) error {
+	var firstErr error
+	for _, sink := range m {
+		if err := sink.Write(ctx, record); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// newAuditSink returns the sink configured by the options, or nil when auditing
+// is disabled, and the senders that post its records in the background
+func newAuditSink(options Options) (AuditSink, []sender, error) {
+	var sinks multiAuditSink
+	var senders []sender
+	if options.AuditLogPath != "" {
+		fileSink, err := NewFileAuditSink(options.AuditLogPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("new file audit sink: %w", err)
+		}
+
+		sinks = append(sinks, fileSink)
+	}
+
+	if options.AuditWebhookURL != "" {
+		webhookSink := newWebhookAuditSink(options.AuditWebhookURL)
+		sinks = append(sinks, webhookSink)
+		senders = append(senders, webhookSink)
+	}
+
+	if len(sinks) == 0 {
+		return nil, nil, nil
+	}
+
+	return sinks, senders, nil
+}
+
+// getLastEditor returns the user that last changed the spec of the Sandbox, or
+// the user that created it, as recorded by the webhook
+func getLastEditor(sandbox operatorsv1alpha1.Sandbox) string {
+	if editor := sandbox.Annotations[updatedByAnnotation]; editor != "" {
+		return editor
+	}
+
+	return sandbox.Annotations[createdByAnnotation]
+}
+
+// audit records an action the actor took on the Sandbox. Failing to record an
+// action is logged rather than failing the reconcile, as the action has already
+// happened. Actions are recorded once the change they made has been saved, so
+// that a retried reconcile does not record them twice.
+func (r *ReconcileSandbox) audit(ctx context.Context, sandbox operatorsv1alpha1.Sandbox, action string, actor string, subjects []rbacv1.Subject, details map[string]string) {
+	if r.auditSink == nil {
+		return
+	}
+
+	record := AuditRecord{
+		Time:      time.Now().UTC(),
+		Action:    action,
+		Sandbox:   sandbox.Name,
+		Namespace: getNamespace(sandbox).Name,
+		Actor:     actor,
+		Owners:    sandbox.Spec.Owners,
+		Subjects:  getSubjectNames(subjects),
+		Details:   details,
+	}
+
+	if err := r.auditSink.Write(ctx, record); err != nil {
+		getLogger(ctx).Error(err, "Write audit record", "action", action)
+	}
+}
+
+// getSubjectNames returns the names of the subjects, which are the object IDs
+// of users resolved through Azure
+func getSubjectNames(subjects []rbacv1.Subject) []string {
+	var names []string
+	for _, subject := range subjects {
+		names = append(names, subject.Name)
+	}
+
+	return names
+}
+
+// getSubjectChanges returns the subjects that were added to and removed from a binding
+func getSubjectChanges(previous []rbacv1.Subject, current []rbacv1.Subject) ([]rbacv1.Subject, []rbacv1.Subject) {
+	contains := func(subjects []rbacv1.Subject, subject rbacv1.Subject) bool {
+		for _, s := range subjects {
+			if s.Kind == subject.Kind && s.Name == subject.Name {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	var added []rbacv1.Subject
+	for _, subject := range current {
+		if !contains(previous, subject) {
+			added = append(added, subject)
+		}
+	}
+
+	var removed []rbacv1.Subject
+	for _, subject := range previous {
+		if !contains(current, subject) {
+			removed = append(removed, subject)
+		}
+	}
+
+	return added, removed
+}
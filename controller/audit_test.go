@@ -0,0 +1,181 @@
+// +build !integration
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/plexsystems/sandbox-operator/apis"
+	operatorsv1alpha1 "github.com/plexsystems/sandbox-operator/apis/operators/v1alpha1"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestSandboxController_Lifecycle_RecordsAuditTrail(t *testing.T) {
+	ctx := context.TODO()
+
+	s := scheme.Scheme
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatalf("add scheme: %v", err)
+	}
+
+	var auditLog bytes.Buffer
+	fakeClient := fake.NewFakeClientWithScheme(s)
+	r := NewReconcileSandbox(fakeClient, fakeClient, s, DefaultSubjects{}, record.NewFakeRecorder(20))
+	r.auditSink = NewJSONLinesAuditSink(&auditLog)
+
+	sandbox := operatorsv1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+			Annotations: map[string]string{
+				createdByAnnotation: "foo@bar.com",
+			},
+		},
+		Spec: operatorsv1alpha1.SandboxSpec{
+			Owners: []string{"foo@bar.com"},
+		},
+	}
+
+	if err := r.client.Create(ctx, &sandbox); err != nil {
+		t.Fatalf("create sandbox: %v", err)
+	}
+
+	request := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name: sandbox.Name,
+		},
+	}
+
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("reconcile sandbox: %v", err)
+	}
+
+	var foundSandbox operatorsv1alpha1.Sandbox
+	if err := r.client.Get(ctx, request.NamespacedName, &foundSandbox); err != nil {
+		t.Fatalf("get sandbox: %v", err)
+	}
+
+	// The fake client does not bump the generation when the spec changes
+	foundSandbox.Generation++
+	foundSandbox.Spec.Owners = []string{"bar@bar.com"}
+	foundSandbox.Annotations[updatedByAnnotation] = "admin@bar.com"
+	if err := r.client.Update(ctx, &foundSandbox); err != nil {
+		t.Fatalf("update sandbox: %v", err)
+	}
+
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("reconcile sandbox: %v", err)
+	}
+
+	var records []AuditRecord
+	for _, line := range strings.Split(strings.TrimSpace(auditLog.String()), "\n") {
+		var record AuditRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("unmarshal audit record: %v", err)
+		}
+
+		records = append(records, record)
+	}
+
+	expected := []AuditRecord{
+		{Action: AuditCreated, Actor: "foo@bar.com", Subjects: []string{"foo@bar.com"}},
+		{Action: AuditOwnerAdded, Actor: "admin@bar.com", Subjects: []string{"bar@bar.com"}},
+		{Action: AuditOwnerRemoved, Actor: "admin@bar.com", Subjects: []string{"foo@bar.com"}},
+	}
+
+	if len(records) != len(expected) {
+		t.Fatalf("expected %d audit records but found %d: %v", len(expected), len(records), records)
+	}
+
+	for i, record := range records {
+		if record.Action != expected[i].Action || record.Actor != expected[i].Actor || strings.Join(record.Subjects, ",") != strings.Join(expected[i].Subjects, ",") {
+			t.Errorf("expected audit record %v but found %v", expected[i], record)
+		}
+	}
+}
+
+func TestWebhookAuditSink_Write_PostsRecord(t *testing.T) {
+	var received AuditRecord
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if err := json.NewDecoder(req.Body).Decode(&received); err != nil {
+			t.Errorf("decode audit record: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	sink := newWebhookAuditSink(server.URL)
+	go sink.Start(make(chan struct{}))
+
+	if err := sink.Write(context.TODO(), AuditRecord{Action: AuditDeleted, Sandbox: "test"}); err != nil {
+		t.Fatalf("write audit record: %v", err)
+	}
+
+	select {
+	case <-sink.Close():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the queued audit record to be posted when the sink is closed")
+	}
+
+	if received.Action != AuditDeleted || received.Sandbox != "test" {
+		t.Errorf("expected the audit record to be posted but received %v", received)
+	}
+}
+
+func TestSandboxWebhook_Delete_RecordsDeleter(t *testing.T) {
+	ctx := context.TODO()
+
+	s := scheme.Scheme
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatalf("add scheme: %v", err)
+	}
+
+	sandbox := operatorsv1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+			Annotations: map[string]string{
+				updatedByAnnotation: "foo@bar.com",
+			},
+		},
+	}
+
+	fakeClient := fake.NewFakeClientWithScheme(s, &sandbox)
+	webhook := sandboxWebhook{client: fakeClient}
+
+	request := admission.Request{
+		AdmissionRequest: admissionv1beta1.AdmissionRequest{
+			Operation: admissionv1beta1.Delete,
+			Name:      sandbox.Name,
+			UserInfo: authenticationv1.UserInfo{
+				Username: "admin@bar.com",
+			},
+		},
+	}
+
+	if response := webhook.Handle(ctx, request); !response.Allowed {
+		t.Fatalf("expected delete to be allowed: %v", response.Result)
+	}
+
+	var foundSandbox operatorsv1alpha1.Sandbox
+	if err := fakeClient.Get(ctx, types.NamespacedName{Name: sandbox.Name}, &foundSandbox); err != nil {
+		t.Fatalf("get sandbox: %v", err)
+	}
+
+	if foundSandbox.Status.DeletedBy != "admin@bar.com" {
+		t.Errorf("expected the deleter to be recorded instead of the last editor but found %q", foundSandbox.Status.DeletedBy)
+	}
+}
@@ -3,8 +3,6 @@ package controller
 import (
 	"context"
 	"fmt"
-	"log"
-	"os"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/services/graphrbac/1.6/graphrbac"
@@ -17,19 +15,25 @@ type AzureSubjects struct {
 	client graphrbac.UsersClient
 }
 
-// NewAzureSubjectsClient creates a new client to get azure users
-func NewAzureSubjectsClient() (*AzureSubjects, error) {
+// NewAzureSubjectsClient creates a new client to get the azure users of the tenant
+func NewAzureSubjectsClient(tenantID string) (*AzureSubjects, error) {
 	const authorizeTimeout = 5 * time.Second
 
 	ctx, cancel := context.WithTimeout(context.TODO(), authorizeTimeout)
 	defer cancel()
 
-	authorizer, err := auth.NewAuthorizerFromEnvironment()
+	settings, err := auth.GetSettingsFromEnvironment()
+	if err != nil {
+		return nil, fmt.Errorf("get settings: %w", err)
+	}
+
+	settings.Values[auth.TenantID] = tenantID
+	authorizer, err := settings.GetAuthorizer()
 	if err != nil {
 		return nil, fmt.Errorf("new authorizer: %w", err)
 	}
 
-	graphClient := graphrbac.NewUsersClient(os.Getenv("AZURE_TENANT_ID"))
+	graphClient := graphrbac.NewUsersClient(tenantID)
 	graphClient.Authorizer = authorizer
 
 	if _, err := graphClient.List(ctx, ""); err != nil {
@@ -43,10 +47,21 @@ func NewAzureSubjectsClient() (*AzureSubjects, error) {
 	return &azureSubjects, nil
 }
 
+// Ping checks that the Graph API can be reached with the credentials of the client
+func (a *AzureSubjects) Ping(ctx context.Context) error {
+	if _, err := a.client.List(ctx, ""); err != nil {
+		return fmt.Errorf("list users: %w", err)
+	}
+
+	return nil
+}
+
 // Subjects gets the ObjectIDs from a list of given emails or user principal names
 func (a *AzureSubjects) Subjects(ctx context.Context, users []string) ([]rbacv1.Subject, error) {
-	var objectIDs []string
+	start := time.Now()
+	logger := getLogger(ctx).WithValues("backend", "azure")
 
+	var objectIDs []string
 	for _, user := range users {
 		filterString := "mail eq '" + user + "' or userPrincipalName eq '" + user + "'"
 		userListResultPage, err := a.client.List(ctx, filterString)
@@ -58,10 +73,12 @@ func (a *AzureSubjects) Subjects(ctx context.Context, users []string) ([]rbacv1.
 		if userListResultPageValues != nil {
 			objectIDs = append(objectIDs, (*userListResultPageValues[0].ObjectID))
 		} else {
-			log.Printf("%s could not be found\n", user)
+			logger.Info("User could not be found", "user", user)
 		}
 	}
 
+	logger.V(1).Info("Resolved subjects", "users", len(users), "subjects", len(objectIDs), "duration", time.Since(start).String())
+
 	subjects := getSubjects(objectIDs)
 
 	return subjects, nil
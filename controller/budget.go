@@ -0,0 +1,203 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	operatorsv1alpha1 "github.com/plexsystems/sandbox-operator/apis/operators/v1alpha1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// HibernateAnnotation asks for the Sandbox to be hibernated while it is "true",
+// regardless of its budget
+const HibernateAnnotation = "operators.plex.dev/hibernate"
+
+// hibernatedReplicasAnnotation holds the replicas a workload had before its
+// Sandbox was hibernated, so that they are restored when it is woken up
+const hibernatedReplicasAnnotation = "operators.plex.dev/hibernated-replicas"
+
+// ParseBudget parses the budget in the spec of a Sandbox, which must be a
+// non-negative number
+func ParseBudget(budget string) (float64, error) {
+	parsed, err := strconv.ParseFloat(budget, 64)
+	if err != nil || parsed < 0 {
+		return 0, fmt.Errorf("budget must be a non-negative number: %s", budget)
+	}
+
+	return parsed, nil
+}
+
+// getBudget returns the budget of the Sandbox, falling back to the default
+// budget of its size, and whether it has a budget at all
+func (r *ReconcileSandbox) getBudget(sandbox operatorsv1alpha1.Sandbox) (float64, bool, error) {
+	if sandbox.Spec.Budget != "" {
+		budget, err := ParseBudget(sandbox.Spec.Budget)
+		if err != nil {
+			return 0, false, err
+		}
+
+		return budget, true, nil
+	}
+
+	budget, ok := r.getOptions().Budgets[GetAppliedSize(sandbox)]
+	return budget, ok, nil
+}
+
+// updateBudgetStatus compares the cost of the Sandbox with its budget, warns
+// its owners as the budget runs out and reports whether the Sandbox is over
+// its budget and should be hibernated
+func (r *ReconcileSandbox) updateBudgetStatus(ctx context.Context, sandbox *operatorsv1alpha1.Sandbox, now time.Time) (bool, error) {
+	budget, ok, err := r.getBudget(*sandbox)
+	if err != nil {
+		return false, fmt.Errorf("get budget: %w", err)
+	}
+
+	if !ok || sandbox.Status.Cost == nil {
+		sandbox.Status.Budget = nil
+		return false, nil
+	}
+
+	spent, _ := getCurrentCost(*sandbox.Status.Cost, now)
+	remaining := budget - spent
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	sandbox.Status.Budget = &operatorsv1alpha1.SandboxBudget{
+		Limit:     formatCost(budget),
+		Spent:     formatCost(spent),
+		Remaining: formatCost(remaining),
+	}
+
+	previous, _ := getCondition(sandbox.Status, operatorsv1alpha1.SandboxConditionBudgetExceeded)
+
+	if spent >= budget {
+		message := fmt.Sprintf("Spent %s of the %s budget, the workloads were scaled down until the budget is raised", formatCost(spent), formatCost(budget))
+		setCondition(&sandbox.Status, operatorsv1alpha1.SandboxConditionBudgetExceeded, corev1.ConditionTrue, "Hibernated", message)
+		if previous.Status != corev1.ConditionTrue {
+			r.recorder.Event(sandbox, corev1.EventTypeWarning, "BudgetExceeded", message)
+			r.notify(ctx, *sandbox, NotificationHibernated, message)
+		}
+
+		return true, nil
+	}
+
+	if spent >= budget*r.getOptions().BudgetWarningThreshold/100 {
+		message := fmt.Sprintf("Spent %s of the %s budget, the sandbox will be hibernated when the budget is spent", formatCost(spent), formatCost(budget))
+		setCondition(&sandbox.Status, operatorsv1alpha1.SandboxConditionBudgetExceeded, corev1.ConditionFalse, "NearBudget", message)
+		if previous.Reason != "NearBudget" {
+			r.recorder.Event(sandbox, corev1.EventTypeWarning, "BudgetWarning", message)
+			r.notify(ctx, *sandbox, NotificationBudgetWarning, message)
+		}
+
+		return false, nil
+	}
+
+	setCondition(&sandbox.Status, operatorsv1alpha1.SandboxConditionBudgetExceeded, corev1.ConditionFalse, "WithinBudget", fmt.Sprintf("%s of the %s budget remains", formatCost(remaining), formatCost(budget)))
+	if previous.Status == corev1.ConditionTrue {
+		r.recorder.Event(sandbox, corev1.EventTypeNormal, "BudgetRaised", "The budget was raised, new pods can be created again")
+	}
+
+	return false, nil
+}
+
+// isHibernationRequested reports whether the Sandbox was asked to be hibernated
+// through its hibernate annotation
+func isHibernationRequested(sandbox operatorsv1alpha1.Sandbox) bool {
+	return sandbox.Annotations[HibernateAnnotation] == "true"
+}
+
+// workload is a Deployment or StatefulSet that can be scaled
+type workload interface {
+	runtime.Object
+	metav1.Object
+}
+
+// scaleWorkloads scales the Deployments and StatefulSets of a hibernated
+// Sandbox down to zero, or restores their replicas when it is woken up.
+// Workloads are read through the apiReader so that hibernation does not start
+// cluster-wide informers for them.
+func (r *ReconcileSandbox) scaleWorkloads(ctx context.Context, sandbox operatorsv1alpha1.Sandbox, hibernate bool) error {
+	namespace := getNamespace(sandbox)
+
+	var deployments appsv1.DeploymentList
+	if err := r.apiReader.List(ctx, &deployments, client.InNamespace(namespace.Name)); err != nil {
+		return fmt.Errorf("list deployments: %w", err)
+	}
+
+	for i := range deployments.Items {
+		deployment := &deployments.Items[i]
+		if err := r.scaleWorkload(ctx, deployment, &deployment.Spec.Replicas, hibernate); err != nil {
+			return fmt.Errorf("scale deployment %s: %w", deployment.Name, err)
+		}
+	}
+
+	var statefulSets appsv1.StatefulSetList
+	if err := r.apiReader.List(ctx, &statefulSets, client.InNamespace(namespace.Name)); err != nil {
+		return fmt.Errorf("list statefulsets: %w", err)
+	}
+
+	for i := range statefulSets.Items {
+		statefulSet := &statefulSets.Items[i]
+		if err := r.scaleWorkload(ctx, statefulSet, &statefulSet.Spec.Replicas, hibernate); err != nil {
+			return fmt.Errorf("scale statefulset %s: %w", statefulSet.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// scaleWorkload scales a workload down to zero and records its replicas in an
+// annotation, or restores the replicas recorded in the annotation
+func (r *ReconcileSandbox) scaleWorkload(ctx context.Context, workload workload, replicas **int32, hibernate bool) error {
+	patch := client.MergeFrom(workload.DeepCopyObject())
+	annotations := workload.GetAnnotations()
+
+	if hibernate {
+		if *replicas != nil && **replicas == 0 {
+			return nil
+		}
+
+		// Workloads without replicas run a single replica by default
+		current := int32(1)
+		if *replicas != nil {
+			current = **replicas
+		}
+
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+
+		annotations[hibernatedReplicasAnnotation] = strconv.Itoa(int(current))
+		*replicas = new(int32)
+	} else {
+		value, ok := annotations[hibernatedReplicasAnnotation]
+		if !ok {
+			return nil
+		}
+
+		restored, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return fmt.Errorf("parse %s: %w", hibernatedReplicasAnnotation, err)
+		}
+
+		restoredReplicas := int32(restored)
+		*replicas = &restoredReplicas
+		delete(annotations, hibernatedReplicasAnnotation)
+	}
+
+	workload.SetAnnotations(annotations)
+	if err := r.client.Patch(ctx, workload, patch); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,296 @@
+// +build !integration
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/plexsystems/sandbox-operator/apis"
+	operatorsv1alpha1 "github.com/plexsystems/sandbox-operator/apis/operators/v1alpha1"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestSandboxController_OverBudget_HibernatesUntilBudgetRaised(t *testing.T) {
+	ctx := context.TODO()
+
+	s := scheme.Scheme
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatalf("add scheme: %v", err)
+	}
+
+	recorder := record.NewFakeRecorder(20)
+	fakeClient := fake.NewFakeClientWithScheme(s)
+	r := NewReconcileSandbox(fakeClient, fakeClient, s, DefaultSubjects{}, recorder)
+	r.options.Prices.CPUHour = 1
+
+	lastAccrued := metav1.NewTime(time.Now())
+	sandbox := operatorsv1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+		Spec: operatorsv1alpha1.SandboxSpec{
+			Budget: "5",
+		},
+		Status: operatorsv1alpha1.SandboxStatus{
+			Cost: &operatorsv1alpha1.SandboxCost{
+				Reserved:    "10.0000",
+				LastAccrued: &lastAccrued,
+			},
+		},
+	}
+
+	if err := r.client.Create(ctx, &sandbox); err != nil {
+		t.Fatalf("create sandbox: %v", err)
+	}
+
+	replicas := int32(3)
+	deployment := appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: getNamespace(sandbox).Name,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+		},
+	}
+
+	if err := r.client.Create(ctx, &deployment); err != nil {
+		t.Fatalf("create deployment: %v", err)
+	}
+
+	request := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name: sandbox.Name,
+		},
+	}
+
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("reconcile sandbox: %v", err)
+	}
+
+	resourceQuota := getResourceQuota(sandbox)
+	if err := r.client.Get(ctx, types.NamespacedName{Name: resourceQuota.Name, Namespace: resourceQuota.Namespace}, &resourceQuota); err != nil {
+		t.Fatalf("get resource quota: %v", err)
+	}
+
+	pods, ok := resourceQuota.Spec.Hard[corev1.ResourcePods]
+	if !ok || !pods.IsZero() {
+		t.Errorf("expected ResourceQuota pods to be 0 but found: %v", resourceQuota.Spec.Hard)
+	}
+
+	var foundSandbox operatorsv1alpha1.Sandbox
+	if err := r.client.Get(ctx, request.NamespacedName, &foundSandbox); err != nil {
+		t.Fatalf("get sandbox: %v", err)
+	}
+
+	if foundSandbox.Status.Phase != operatorsv1alpha1.SandboxPhaseHibernated {
+		t.Errorf("expected sandbox to be Hibernated but was %s", foundSandbox.Status.Phase)
+	}
+
+	if foundSandbox.Status.Budget == nil || foundSandbox.Status.Budget.Remaining != "0.0000" {
+		t.Errorf("expected no budget to remain but found: %v", foundSandbox.Status.Budget)
+	}
+
+	if !hasEvent(recorder, "BudgetExceeded") {
+		t.Errorf("expected a BudgetExceeded event but none was recorded")
+	}
+
+	if err := r.client.Get(ctx, types.NamespacedName{Name: deployment.Name, Namespace: deployment.Namespace}, &deployment); err != nil {
+		t.Fatalf("get deployment: %v", err)
+	}
+
+	if deployment.Spec.Replicas == nil || *deployment.Spec.Replicas != 0 || deployment.Annotations[hibernatedReplicasAnnotation] != "3" {
+		t.Errorf("expected deployment to be scaled down from 3 replicas but found %v replicas and annotations %v", deployment.Spec.Replicas, deployment.Annotations)
+	}
+
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("reconcile sandbox: %v", err)
+	}
+
+	if err := r.client.Get(ctx, request.NamespacedName, &foundSandbox); err != nil {
+		t.Fatalf("get sandbox: %v", err)
+	}
+
+	if foundSandbox.Status.Cost == nil || foundSandbox.Status.Cost.ReservedHourly != "0.0000" {
+		t.Errorf("expected a hibernated sandbox to not accrue reserved cost but found: %v", foundSandbox.Status.Cost)
+	}
+
+	foundSandbox.Spec.Budget = "100"
+	if err := r.client.Update(ctx, &foundSandbox); err != nil {
+		t.Fatalf("update sandbox: %v", err)
+	}
+
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("reconcile sandbox: %v", err)
+	}
+
+	if err := r.client.Get(ctx, types.NamespacedName{Name: resourceQuota.Name, Namespace: resourceQuota.Namespace}, &resourceQuota); err != nil {
+		t.Fatalf("get resource quota: %v", err)
+	}
+
+	if _, ok := resourceQuota.Spec.Hard[corev1.ResourcePods]; ok {
+		t.Errorf("expected ResourceQuota pods limit to be removed but found: %v", resourceQuota.Spec.Hard)
+	}
+
+	if err := r.client.Get(ctx, request.NamespacedName, &foundSandbox); err != nil {
+		t.Fatalf("get sandbox: %v", err)
+	}
+
+	if foundSandbox.Status.Phase != operatorsv1alpha1.SandboxPhaseActive {
+		t.Errorf("expected sandbox to be Active but was %s", foundSandbox.Status.Phase)
+	}
+
+	if err := r.client.Get(ctx, types.NamespacedName{Name: deployment.Name, Namespace: deployment.Namespace}, &deployment); err != nil {
+		t.Fatalf("get deployment: %v", err)
+	}
+
+	if deployment.Spec.Replicas == nil || *deployment.Spec.Replicas != 3 {
+		t.Errorf("expected deployment to be scaled back to 3 replicas but found %v", deployment.Spec.Replicas)
+	}
+
+	if _, ok := deployment.Annotations[hibernatedReplicasAnnotation]; ok {
+		t.Errorf("expected %s to be removed from the deployment", hibernatedReplicasAnnotation)
+	}
+}
+
+func TestSandboxController_HibernateAnnotation_HibernatesUntilRemoved(t *testing.T) {
+	ctx := context.TODO()
+
+	s := scheme.Scheme
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatalf("add scheme: %v", err)
+	}
+
+	recorder := record.NewFakeRecorder(20)
+	fakeClient := fake.NewFakeClientWithScheme(s)
+	r := NewReconcileSandbox(fakeClient, fakeClient, s, DefaultSubjects{}, recorder)
+
+	sandbox := operatorsv1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+			Annotations: map[string]string{
+				HibernateAnnotation: "true",
+				updatedByAnnotation: "foo@bar.com",
+			},
+		},
+	}
+
+	if err := r.client.Create(ctx, &sandbox); err != nil {
+		t.Fatalf("create sandbox: %v", err)
+	}
+
+	request := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name: sandbox.Name,
+		},
+	}
+
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("reconcile sandbox: %v", err)
+	}
+
+	var foundSandbox operatorsv1alpha1.Sandbox
+	if err := r.client.Get(ctx, request.NamespacedName, &foundSandbox); err != nil {
+		t.Fatalf("get sandbox: %v", err)
+	}
+
+	if foundSandbox.Status.Phase != operatorsv1alpha1.SandboxPhaseHibernated {
+		t.Errorf("expected sandbox to be Hibernated but was %s", foundSandbox.Status.Phase)
+	}
+
+	if !hasEvent(recorder, "Hibernated") {
+		t.Errorf("expected a Hibernated event but none was recorded")
+	}
+
+	delete(foundSandbox.Annotations, HibernateAnnotation)
+	if err := r.client.Update(ctx, &foundSandbox); err != nil {
+		t.Fatalf("update sandbox: %v", err)
+	}
+
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("reconcile sandbox: %v", err)
+	}
+
+	if err := r.client.Get(ctx, request.NamespacedName, &foundSandbox); err != nil {
+		t.Fatalf("get sandbox: %v", err)
+	}
+
+	if foundSandbox.Status.Phase != operatorsv1alpha1.SandboxPhaseActive {
+		t.Errorf("expected sandbox to be Active but was %s", foundSandbox.Status.Phase)
+	}
+
+	if !hasEvent(recorder, "Woken") {
+		t.Errorf("expected a Woken event but none was recorded")
+	}
+}
+
+func TestSandboxWebhook_NonBudgetAdmin_IsDenied(t *testing.T) {
+	webhook := sandboxWebhook{budgetAdminGroup: "admins"}
+
+	getSandbox := func(budget string) []byte {
+		sandbox := operatorsv1alpha1.Sandbox{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "operators.plex.dev/v1alpha1",
+				Kind:       "Sandbox",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "test",
+			},
+			Spec: operatorsv1alpha1.SandboxSpec{
+				Budget: budget,
+			},
+		}
+
+		sandboxJSON, err := json.Marshal(sandbox)
+		if err != nil {
+			t.Fatalf("marshal sandbox: %v", err)
+		}
+
+		return sandboxJSON
+	}
+
+	request := admission.Request{
+		AdmissionRequest: admissionv1beta1.AdmissionRequest{
+			Operation: admissionv1beta1.Update,
+			Object:    runtime.RawExtension{Raw: getSandbox("100")},
+			OldObject: runtime.RawExtension{Raw: getSandbox("5")},
+			UserInfo: authenticationv1.UserInfo{
+				Username: "owner@bar.com",
+			},
+		},
+	}
+
+	if response := webhook.Handle(context.TODO(), request); response.Allowed {
+		t.Errorf("expected owner raising the budget to be denied")
+	}
+
+	request.Operation = admissionv1beta1.Create
+	request.OldObject = runtime.RawExtension{}
+	if response := webhook.Handle(context.TODO(), request); response.Allowed {
+		t.Errorf("expected owner creating a sandbox with a budget to be denied")
+	}
+
+	request.UserInfo.Groups = []string{"admins"}
+	if response := webhook.Handle(context.TODO(), request); !response.Allowed {
+		t.Errorf("expected budget admin to be allowed: %v", response.Result)
+	}
+	request.Object = runtime.RawExtension{Raw: getSandbox("1Ki")}
+	if response := webhook.Handle(context.TODO(), request); response.Allowed {
+		t.Errorf("expected a budget that is not a number to be denied")
+	}
+}
@@ -0,0 +1,231 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	operatorsv1alpha1 "github.com/plexsystems/sandbox-operator/apis/operators/v1alpha1"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ImportBundle creates a new Sandbox and applies the objects found in the
+// bundle into its namespace. The bundle is a YAML or JSON stream of manifests.
+//
+// Every object is validated against the sandbox owner Role before anything is
+// created, and the objects are created with the given client once the owner
+// Role is bound, so the client should be authenticated as one of the owners.
+// When an object cannot be created, the objects created before it and the
+// Sandbox are deleted again so that the bundle is not left half applied. A
+// Sandbox that needs its creation approved is deleted right away, as its
+// namespace is only created once it is approved.
+func ImportBundle(ctx context.Context, client client.Client, sandbox operatorsv1alpha1.Sandbox, bundle io.Reader) error {
+	objects, err := getBundleObjects(sandbox, bundle)
+	if err != nil {
+		return fmt.Errorf("get bundle objects: %w", err)
+	}
+
+	if err := client.Create(ctx, &sandbox); err != nil {
+		return fmt.Errorf("create Sandbox: %w", err)
+	}
+
+	if err := waitForOwnerAccess(ctx, client, sandbox, objects); err != nil {
+		return removeSandbox(ctx, client, sandbox, fmt.Errorf("wait for owner access: %w", err))
+	}
+
+	if err := applyBundleObjects(ctx, client, objects); err != nil {
+		return removeSandbox(ctx, client, sandbox, fmt.Errorf("apply bundle: %w", err))
+	}
+
+	return nil
+}
+
+// removeSandbox deletes the Sandbox of a bundle that failed, and returns the
+// error of the bundle along with whether the Sandbox could be removed
+func removeSandbox(ctx context.Context, client client.Client, sandbox operatorsv1alpha1.Sandbox, bundleErr error) error {
+	if err := client.Delete(ctx, &sandbox); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("%w, and could not remove Sandbox %s: %v", bundleErr, sandbox.Name, err)
+	}
+
+	return fmt.Errorf("%w, removed Sandbox %s", bundleErr, sandbox.Name)
+}
+
+// waitForOwnerAccess waits until the caller is allowed to create every kind
+// of object in the namespace of the Sandbox, which is once the operator
+// created the namespace and bound the owner Role in it. Owners cannot read
+// namespaces or role bindings, so their access is checked with
+// SelfSubjectAccessReviews. It fails right away when the Sandbox is waiting
+// for approval, as the namespace is not created until a reviewer approves it.
+func waitForOwnerAccess(ctx context.Context, client client.Client, sandbox operatorsv1alpha1.Sandbox, objects []unstructured.Unstructured) error {
+	const intervalTime = 2 * time.Second
+	const waitTime = 60 * time.Second
+
+	var resources []schema.GroupVersionResource
+	for _, object := range objects {
+		resource, _ := meta.UnsafeGuessKindToResource(object.GroupVersionKind())
+		if !containsResource(resources, resource) {
+			resources = append(resources, resource)
+		}
+	}
+
+	// Without objects, access to the namespace is checked with the first
+	// resource every owner can create
+	if len(resources) == 0 {
+		resources = append(resources, corev1.SchemeGroupVersion.WithResource("configmaps"))
+	}
+
+	namespace := getNamespace(sandbox).Name
+	return wait.PollImmediate(intervalTime, waitTime, func() (bool, error) {
+		// The caller may not be allowed to get the Sandbox until it is bound
+		// as an owner, so failing to get it is not an error
+		var current operatorsv1alpha1.Sandbox
+		if err := client.Get(ctx, types.NamespacedName{Name: sandbox.Name}, &current); err == nil {
+			switch current.Status.Phase {
+			case operatorsv1alpha1.SandboxPhaseAwaitingApproval, operatorsv1alpha1.SandboxPhaseRejected:
+				return false, fmt.Errorf("the sandbox needs its creation approved, create it without the bundle and apply the bundle once it is approved")
+			}
+		}
+
+		for _, resource := range resources {
+			review := authorizationv1.SelfSubjectAccessReview{
+				Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+					ResourceAttributes: &authorizationv1.ResourceAttributes{
+						Namespace: namespace,
+						Verb:      "create",
+						Group:     resource.Group,
+						Resource:  resource.Resource,
+					},
+				},
+			}
+
+			if err := client.Create(ctx, &review); err != nil {
+				return false, fmt.Errorf("review access: %w", err)
+			}
+
+			if !review.Status.Allowed {
+				return false, nil
+			}
+		}
+
+		return true, nil
+	})
+}
+
+// applyBundleObjects creates the objects. When an object cannot be created,
+// the objects that were created are deleted and the error names any object
+// that could not be deleted.
+func applyBundleObjects(ctx context.Context, client client.Client, objects []unstructured.Unstructured) error {
+	for i := range objects {
+		if err := client.Create(ctx, &objects[i]); err != nil {
+			createErr := fmt.Errorf("create %s %s: %w", objects[i].GetKind(), objects[i].GetName(), err)
+
+			var remaining []string
+			for j := i - 1; j >= 0; j-- {
+				if err := client.Delete(ctx, &objects[j]); err != nil && !errors.IsNotFound(err) {
+					remaining = append(remaining, fmt.Sprintf("%s %s", objects[j].GetKind(), objects[j].GetName()))
+				}
+			}
+
+			if len(remaining) > 0 {
+				return fmt.Errorf("%w, and could not remove %s", createErr, strings.Join(remaining, ", "))
+			}
+
+			return fmt.Errorf("%w, removed the %d objects already created", createErr, i)
+		}
+	}
+
+	return nil
+}
+
+func getBundleObjects(sandbox operatorsv1alpha1.Sandbox, bundle io.Reader) ([]unstructured.Unstructured, error) {
+	role := getRole(sandbox)
+
+	var objects []unstructured.Unstructured
+	decoder := yaml.NewYAMLOrJSONDecoder(bundle, 4096)
+	for {
+		var object unstructured.Unstructured
+		if err := decoder.Decode(&object.Object); err != nil {
+			if err == io.EOF {
+				break
+			}
+
+			return nil, fmt.Errorf("decode object: %w", err)
+		}
+
+		if len(object.Object) == 0 {
+			continue
+		}
+
+		gvk := object.GroupVersionKind()
+		if !isAllowedByRole(role, gvk) {
+			return nil, fmt.Errorf("%s %s is not allowed in a sandbox", gvk.Kind, object.GetName())
+		}
+
+		object.SetNamespace(role.Namespace)
+		object.SetResourceVersion("")
+		object.SetUID("")
+		object.SetSelfLink("")
+		object.SetCreationTimestamp(metav1.Time{})
+		object.SetOwnerReferences(nil)
+		unstructured.RemoveNestedField(object.Object, "status")
+
+		objects = append(objects, object)
+	}
+
+	return objects, nil
+}
+
+func isAllowedByRole(role rbacv1.Role, gvk schema.GroupVersionKind) bool {
+	resource, _ := meta.UnsafeGuessKindToResource(gvk)
+
+	for _, rule := range role.Rules {
+		if !ContainsString(rule.APIGroups, gvk.Group) {
+			continue
+		}
+
+		if !ContainsString(rule.Resources, resource.Resource) {
+			continue
+		}
+
+		if ContainsString(rule.Verbs, "*") || ContainsString(rule.Verbs, "create") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ContainsString reports whether the value is one of the values
+func ContainsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsResource(resources []schema.GroupVersionResource, resource schema.GroupVersionResource) bool {
+	for _, r := range resources {
+		if r == resource {
+			return true
+		}
+	}
+
+	return false
+}
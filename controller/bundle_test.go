@@ -0,0 +1,165 @@
+// +build !integration
+
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/plexsystems/sandbox-operator/apis"
+	operatorsv1alpha1 "github.com/plexsystems/sandbox-operator/apis/operators/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestGetBundleObjects_AllowedKinds_RewritesNamespace(t *testing.T) {
+	sandbox := operatorsv1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+	}
+
+	bundle := `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  namespace: sandbox-other
+  resourceVersion: "42"
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+status:
+  replicas: 1
+`
+
+	objects, err := getBundleObjects(sandbox, strings.NewReader(bundle))
+	if err != nil {
+		t.Fatalf("get bundle objects: %v", err)
+	}
+
+	if len(objects) != 2 {
+		t.Fatalf("expected 2 objects but got %d", len(objects))
+	}
+
+	for _, object := range objects {
+		if object.GetNamespace() != "sandbox-test" {
+			t.Errorf("expected namespace to be rewritten to sandbox-test but was %s", object.GetNamespace())
+		}
+
+		if object.GetResourceVersion() != "" {
+			t.Errorf("expected resourceVersion to be removed but was %s", object.GetResourceVersion())
+		}
+
+		if _, ok := object.Object["status"]; ok {
+			t.Errorf("expected status to be removed from %s", object.GetName())
+		}
+	}
+}
+
+func TestGetBundleObjects_DisallowedKind_ReturnsError(t *testing.T) {
+	sandbox := operatorsv1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+	}
+
+	bundle := `
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: escalate
+`
+
+	if _, err := getBundleObjects(sandbox, strings.NewReader(bundle)); err == nil {
+		t.Errorf("expected ClusterRoleBinding to be rejected but it was not")
+	}
+}
+
+func TestApplyBundleObjects_CreateFails_RemovesCreatedObjects(t *testing.T) {
+	ctx := context.TODO()
+
+	sandbox := operatorsv1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+	}
+
+	bundle := `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: taken
+`
+
+	objects, err := getBundleObjects(sandbox, strings.NewReader(bundle))
+	if err != nil {
+		t.Fatalf("get bundle objects: %v", err)
+	}
+
+	taken := corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "taken",
+			Namespace: "sandbox-test",
+		},
+	}
+
+	fakeClient := fake.NewFakeClientWithScheme(scheme.Scheme, &taken)
+	if err := applyBundleObjects(ctx, fakeClient, objects); err == nil {
+		t.Fatalf("expected the existing ConfigMap to fail the bundle")
+	}
+
+	err = fakeClient.Get(ctx, types.NamespacedName{Name: "settings", Namespace: "sandbox-test"}, &corev1.ConfigMap{})
+	if !errors.IsNotFound(err) {
+		t.Errorf("expected the created ConfigMap to be removed but got %v", err)
+	}
+}
+
+func TestImportBundle_AwaitingApproval_RemovesSandbox(t *testing.T) {
+	ctx := context.TODO()
+
+	s := scheme.Scheme
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatalf("add scheme: %v", err)
+	}
+
+	sandbox := operatorsv1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+		Status: operatorsv1alpha1.SandboxStatus{
+			Phase: operatorsv1alpha1.SandboxPhaseAwaitingApproval,
+		},
+	}
+
+	bundle := `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+`
+
+	fakeClient := fake.NewFakeClientWithScheme(s)
+	err := ImportBundle(ctx, fakeClient, sandbox, strings.NewReader(bundle))
+	if err == nil || !strings.Contains(err.Error(), "approved") {
+		t.Fatalf("expected the bundle to fail while the sandbox awaits approval but got %v", err)
+	}
+
+	err = fakeClient.Get(ctx, types.NamespacedName{Name: "test"}, &operatorsv1alpha1.Sandbox{})
+	if !errors.IsNotFound(err) {
+		t.Errorf("expected the sandbox to be removed but got %v", err)
+	}
+}
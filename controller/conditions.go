@@ -0,0 +1,54 @@
+package controller
+
+import (
+	operatorsv1alpha1 "github.com/plexsystems/sandbox-operator/apis/operators/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// setCondition adds or updates the condition of the given type. The transition
+// time is only changed when the status of the condition changes.
+func setCondition(status *operatorsv1alpha1.SandboxStatus, conditionType operatorsv1alpha1.SandboxConditionType, conditionStatus corev1.ConditionStatus, reason string, message string) {
+	setConditionIn(&status.Conditions, conditionType, conditionStatus, reason, message)
+}
+
+// setConditionIn adds or updates the condition of the given type in the
+// conditions, and reports whether the condition changed
+func setConditionIn(conditions *[]operatorsv1alpha1.SandboxCondition, conditionType operatorsv1alpha1.SandboxConditionType, conditionStatus corev1.ConditionStatus, reason string, message string) bool {
+	condition := operatorsv1alpha1.SandboxCondition{
+		Type:               conditionType,
+		Status:             conditionStatus,
+		Reason:             reason,
+		Message:            message,
+		LastTransitionTime: metav1.Now(),
+	}
+
+	for i, existing := range *conditions {
+		if existing.Type != conditionType {
+			continue
+		}
+
+		if existing.Status == conditionStatus {
+			condition.LastTransitionTime = existing.LastTransitionTime
+		}
+
+		changed := existing.Status != conditionStatus || existing.Reason != reason || existing.Message != message
+		(*conditions)[i] = condition
+		return changed
+	}
+
+	*conditions = append(*conditions, condition)
+	return true
+}
+
+// getCondition returns the condition of the given type if the Sandbox has one
+func getCondition(status operatorsv1alpha1.SandboxStatus, conditionType operatorsv1alpha1.SandboxConditionType) (operatorsv1alpha1.SandboxCondition, bool) {
+	for _, condition := range status.Conditions {
+		if condition.Type == conditionType {
+			return condition, true
+		}
+	}
+
+	return operatorsv1alpha1.SandboxCondition{}, false
+}
@@ -0,0 +1,255 @@
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+// Identity providers that resolve the owners of Sandboxes
+const (
+	identityProviderDefault = "default"
+	identityProviderAzure   = "azure"
+)
+
+// Config is the operator configuration file. Settings in the file override
+// the defaults, and are overridden by environment variables.
+type Config struct {
+	IdentityProvider IdentityProviderConfig `json:"identityProvider,omitempty"`
+	PullSecrets      PullSecretsConfig      `json:"pullSecrets,omitempty"`
+	Defaults         DefaultsConfig         `json:"defaults,omitempty"`
+	Limits           LimitsConfig           `json:"limits,omitempty"`
+}
+
+// IdentityProviderConfig configures how owners are resolved into subjects
+type IdentityProviderConfig struct {
+	// Type is default or azure. Azure credentials are still read from the environment.
+	Type     string `json:"type,omitempty"`
+	TenantID string `json:"tenantID,omitempty"`
+}
+
+// PullSecretsConfig configures the pull secrets copied into every Sandbox
+type PullSecretsConfig struct {
+	Names     []string `json:"names,omitempty"`
+	Namespace string   `json:"namespace,omitempty"`
+}
+
+// DefaultsConfig configures the defaults of Sandboxes
+type DefaultsConfig struct {
+	// Budgets is the budget of a Sandbox by size when the Sandbox does not set one
+	Budgets              map[string]float64 `json:"budgets,omitempty"`
+	RejectionGracePeriod *metav1.Duration   `json:"rejectionGracePeriod,omitempty"`
+	ResyncPeriod         *metav1.Duration   `json:"resyncPeriod,omitempty"`
+}
+
+// LimitsConfig configures the limits the operator works within
+type LimitsConfig struct {
+	MaxConcurrentReconciles int     `json:"maxConcurrentReconciles,omitempty"`
+	QuotaPressureThreshold  int64   `json:"quotaPressureThreshold,omitempty"`
+	BudgetWarningThreshold  float64 `json:"budgetWarningThreshold,omitempty"`
+}
+
+// LoadConfig reads the operator configuration from a YAML or JSON file
+func LoadConfig(path string) (Config, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("read config: %w", err)
+	}
+
+	var config Config
+	if err := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(content), 4096).Decode(&config); err != nil {
+		return Config{}, fmt.Errorf("decode config: %w", err)
+	}
+
+	return config, nil
+}
+
+// apply validates the config and overrides the options with its settings
+func (c Config) apply(options *Options) error {
+	switch c.IdentityProvider.Type {
+	case "":
+	case identityProviderDefault, identityProviderAzure:
+		options.IdentityProvider = c.IdentityProvider.Type
+	default:
+		return fmt.Errorf("identityProvider.type must be %s or %s: %s", identityProviderDefault, identityProviderAzure, c.IdentityProvider.Type)
+	}
+
+	if c.IdentityProvider.TenantID != "" {
+		options.AzureTenantID = c.IdentityProvider.TenantID
+	}
+
+	if len(c.PullSecrets.Names) > 0 {
+		options.PullSecretNames = c.PullSecrets.Names
+	}
+
+	if c.PullSecrets.Namespace != "" {
+		options.PullSecretNamespace = c.PullSecrets.Namespace
+	}
+
+	budgets := make(map[string]float64)
+	for size, budget := range options.Budgets {
+		budgets[size] = budget
+	}
+
+	for size, budget := range c.Defaults.Budgets {
+		if !ContainsString(Sizes, size) || budget <= 0 {
+			return fmt.Errorf("defaults.budgets must be positive budgets of the Sizes %s: %s", strings.Join(Sizes, ", "), size)
+		}
+
+		budgets[size] = budget
+	}
+
+	options.Budgets = budgets
+
+	if c.Defaults.RejectionGracePeriod != nil {
+		options.RejectionGracePeriod = c.Defaults.RejectionGracePeriod.Duration
+	}
+
+	if c.Defaults.ResyncPeriod != nil {
+		options.ResyncPeriod = c.Defaults.ResyncPeriod.Duration
+	}
+
+	if c.Limits.MaxConcurrentReconciles < 0 {
+		return fmt.Errorf("limits.maxConcurrentReconciles must be a positive integer: %d", c.Limits.MaxConcurrentReconciles)
+	}
+
+	if c.Limits.MaxConcurrentReconciles > 0 {
+		options.MaxConcurrentReconciles = c.Limits.MaxConcurrentReconciles
+	}
+
+	if c.Limits.QuotaPressureThreshold < 0 || c.Limits.QuotaPressureThreshold > 100 {
+		return fmt.Errorf("limits.quotaPressureThreshold must be a percentage between 1 and 100: %d", c.Limits.QuotaPressureThreshold)
+	}
+
+	if c.Limits.QuotaPressureThreshold > 0 {
+		options.QuotaPressureThreshold = c.Limits.QuotaPressureThreshold
+	}
+
+	if c.Limits.BudgetWarningThreshold < 0 || c.Limits.BudgetWarningThreshold > 100 {
+		return fmt.Errorf("limits.budgetWarningThreshold must be a percentage between 1 and 100: %v", c.Limits.BudgetWarningThreshold)
+	}
+
+	if c.Limits.BudgetWarningThreshold > 0 {
+		options.BudgetWarningThreshold = c.Limits.BudgetWarningThreshold
+	}
+
+	return nil
+}
+
+// configReloader applies the options again whenever the config file changes.
+// A ConfigMap mounted as a volume is updated by the kubelet in place, so the
+// file is polled rather than watched.
+type configReloader struct {
+	path       string
+	interval   time.Duration
+	reconciler *ReconcileSandbox
+
+	// reloaded is sent an event after a reload so that every Sandbox is
+	// reconciled with the new options
+	reloaded chan<- event.GenericEvent
+}
+
+// NeedLeaderElection reports that every replica reloads its config, so that
+// a standby has the current config when it becomes the leader
+func (c configReloader) NeedLeaderElection() bool {
+	return false
+}
+
+// Start polls the config file until it is stopped
+func (c configReloader) Start(stop <-chan struct{}) error {
+	logger := log.WithValues("config", c.path)
+
+	previous, err := ioutil.ReadFile(c.path)
+	if err != nil {
+		logger.Error(err, "Read config")
+	}
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+
+		content, err := ioutil.ReadFile(c.path)
+		if err != nil {
+			logger.Error(err, "Read config")
+			continue
+		}
+
+		if bytes.Equal(content, previous) {
+			continue
+		}
+
+		previous = content
+
+		options, err := GetOptions()
+		if err != nil {
+			logger.Error(err, "Invalid config was not applied")
+			continue
+		}
+
+		if err := c.reconciler.reload(options); err != nil {
+			logger.Error(err, "Reload config")
+			continue
+		}
+
+		logger.Info("Reloaded config")
+
+		// The event is dropped when a reload is already waiting to be
+		// reconciled, or when this replica is not the leader
+		select {
+		case c.reloaded <- event.GenericEvent{Meta: &metav1.ObjectMeta{Name: c.path}}:
+		default:
+		}
+	}
+}
+
+// reload replaces the options of the reconciler, and the identity provider
+// when it was changed. Options that are only used at startup, such as the
+// number of concurrent reconciles, take effect on the next restart.
+func (r *ReconcileSandbox) reload(options Options) error {
+	current := r.getOptions()
+
+	subjectsClient := r.getSubjectsClient()
+	if options.IdentityProvider != current.IdentityProvider || options.AzureTenantID != current.AzureTenantID {
+		var err error
+		subjectsClient, err = newSubjectsClient(options)
+		if err != nil {
+			return fmt.Errorf("new subjects: %w", err)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.options = options
+	r.subjectsClient = subjectsClient
+
+	return nil
+}
+
+// getOptions returns the options the reconciler is currently using
+func (r *ReconcileSandbox) getOptions() Options {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.options
+}
+
+// getSubjectsClient returns the client the reconciler currently resolves owners with
+func (r *ReconcileSandbox) getSubjectsClient() SubjectsClient {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.subjectsClient
+}
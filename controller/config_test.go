@@ -0,0 +1,104 @@
+// +build !integration
+
+package controller
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestGetOptions_ConfigFile_OverriddenByEnvironment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatalf("create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yaml")
+	config := `
+pullSecrets:
+  names:
+  - registry-a
+  namespace: registries
+defaults:
+  budgets:
+    small: 50
+  rejectionGracePeriod: 1h
+limits:
+  quotaPressureThreshold: 75
+`
+
+	if err := ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	os.Setenv("OPERATOR_CONFIG", path)
+	os.Setenv("PULL_SECRET_NAMESPACE", "default")
+	defer os.Unsetenv("OPERATOR_CONFIG")
+	defer os.Unsetenv("PULL_SECRET_NAMESPACE")
+
+	options, err := GetOptions()
+	if err != nil {
+		t.Fatalf("get options: %v", err)
+	}
+
+	if !reflect.DeepEqual(options.PullSecretNames, []string{"registry-a"}) {
+		t.Errorf("expected pull secrets from the config file but found %v", options.PullSecretNames)
+	}
+
+	if options.PullSecretNamespace != "default" {
+		t.Errorf("expected the environment to override the pull secret namespace but found %s", options.PullSecretNamespace)
+	}
+
+	if options.Budgets["small"] != 50 || options.RejectionGracePeriod != time.Hour || options.QuotaPressureThreshold != 75 {
+		t.Errorf("expected defaults and limits from the config file but found %v, %s and %d", options.Budgets, options.RejectionGracePeriod, options.QuotaPressureThreshold)
+	}
+
+	invalid := "identityProvider:\n  type: azure\n"
+	if err := ioutil.WriteFile(path, []byte(invalid), 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	if _, err := GetOptions(); err == nil {
+		t.Errorf("expected azure without a tenant to be rejected")
+	}
+
+	os.Setenv("AZURE_TENANT_ID", "tenant")
+	defer os.Unsetenv("AZURE_TENANT_ID")
+
+	options, err = GetOptions()
+	if err != nil {
+		t.Fatalf("expected the tenant to be read from the environment: %v", err)
+	}
+
+	if options.IdentityProvider != identityProviderAzure || options.AzureTenantID != "tenant" {
+		t.Errorf("expected the azure identity provider of the tenant but found %s and %s", options.IdentityProvider, options.AzureTenantID)
+	}
+}
+
+func TestReconcileSandbox_Reload_ReplacesOptions(t *testing.T) {
+	fakeClient := fake.NewFakeClientWithScheme(scheme.Scheme)
+	r := NewReconcileSandbox(fakeClient, fakeClient, scheme.Scheme, DefaultSubjects{}, &record.FakeRecorder{})
+
+	options := DefaultOptions()
+	options.PullSecretNames = []string{"registry"}
+	if err := r.reload(options); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+
+	if !reflect.DeepEqual(r.getOptions().PullSecretNames, []string{"registry"}) {
+		t.Errorf("expected reloaded pull secrets but found %v", r.getOptions().PullSecretNames)
+	}
+
+	if _, ok := r.getSubjectsClient().(DefaultSubjects); !ok {
+		t.Errorf("expected the subjects client to be kept when the identity provider did not change")
+	}
+}
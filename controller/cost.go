@@ -0,0 +1,277 @@
+package controller
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	operatorsv1alpha1 "github.com/plexsystems/sandbox-operator/apis/operators/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// costCenterLabel groups the cost of Sandboxes in the cost report
+const costCenterLabel = "operators.plex.dev/cost-center"
+
+// costAccrualPeriod is how often the cost in the status of a Sandbox is accrued
+// when its resources have not changed
+const costAccrualPeriod = time.Hour
+
+const hoursPerMonth = 730
+
+// updateCostStatus accrues the cost of the Sandbox since it was last accrued at
+// the hourly rate that applied since then, and sets the hourly rate from the
+// current ResourceQuota. Costs are only accrued when the rate changes or the
+// accrual period has passed, so that updating the status does not cause
+// another reconcile to update it again.
+func (r *ReconcileSandbox) updateCostStatus(ctx context.Context, sandbox *operatorsv1alpha1.Sandbox, now time.Time) error {
+	prices := r.getOptions().Prices
+	if !prices.enabled() {
+		return nil
+	}
+
+	resourceQuota := getResourceQuota(*sandbox)
+	if err := r.client.Get(ctx, types.NamespacedName{Name: resourceQuota.Name, Namespace: resourceQuota.Namespace}, &resourceQuota); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+
+		return fmt.Errorf("get ResourceQuota: %w", err)
+	}
+
+	// The workloads of a hibernated Sandbox are scaled down, so it does not
+	// reserve the resources of its quota until it is woken up
+	var reservedHourly float64
+	if sandbox.Status.Phase != operatorsv1alpha1.SandboxPhaseHibernated {
+		reservedHourly = prices.getHourlyCost(resourceQuota.Spec.Hard)
+	}
+
+	var requestedHourly float64
+	if prices.IncludeRequested {
+		requestedHourly = prices.getHourlyCost(resourceQuota.Status.Used)
+	}
+
+	if sandbox.Status.Cost == nil {
+		createdAt := sandbox.CreationTimestamp
+		if createdAt.IsZero() {
+			createdAt = metav1.NewTime(now)
+		}
+
+		sandbox.Status.Cost = &operatorsv1alpha1.SandboxCost{
+			LastAccrued: &createdAt,
+		}
+	}
+
+	cost := sandbox.Status.Cost
+	rateChanged := cost.ReservedHourly != formatCost(reservedHourly) || cost.RequestedHourly != formatCost(requestedHourly)
+	if !rateChanged && cost.LastAccrued != nil && now.Sub(cost.LastAccrued.Time) < costAccrualPeriod {
+		return nil
+	}
+
+	reserved, requested := getCurrentCost(*cost, now)
+
+	lastAccrued := metav1.NewTime(now)
+	cost.Reserved = formatCost(reserved)
+	cost.Requested = formatCost(requested)
+	cost.ReservedHourly = formatCost(reservedHourly)
+	cost.RequestedHourly = formatCost(requestedHourly)
+	cost.LastAccrued = &lastAccrued
+
+	return nil
+}
+
+// getHourlyCost returns the hourly cost of the requested CPU, memory and storage in the given resources
+func (p Prices) getHourlyCost(resources corev1.ResourceList) float64 {
+	const gibibyte = 1 << 30
+
+	cpu := resources[corev1.ResourceRequestsCPU]
+	memory := resources[corev1.ResourceRequestsMemory]
+	storage := resources[corev1.ResourceRequestsStorage]
+
+	hourlyCost := float64(cpu.MilliValue()) / 1000 * p.CPUHour
+	hourlyCost += float64(memory.Value()) / gibibyte * p.MemoryGiBHour
+	hourlyCost += float64(storage.Value()) / gibibyte * p.StorageGiBMonth / hoursPerMonth
+
+	return hourlyCost
+}
+
+// getCurrentCost returns the reserved and requested cost of a Sandbox up until
+// the given time, including the cost since it was last accrued
+func getCurrentCost(cost operatorsv1alpha1.SandboxCost, now time.Time) (float64, float64) {
+	reserved := parseCost(cost.Reserved)
+	requested := parseCost(cost.Requested)
+
+	if cost.LastAccrued != nil && now.After(cost.LastAccrued.Time) {
+		hours := now.Sub(cost.LastAccrued.Time).Hours()
+		reserved += parseCost(cost.ReservedHourly) * hours
+		requested += parseCost(cost.RequestedHourly) * hours
+	}
+
+	return reserved, requested
+}
+
+func parseCost(value string) float64 {
+	if value == "" {
+		return 0
+	}
+
+	cost, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+
+	return cost
+}
+
+func formatCost(cost float64) string {
+	return strconv.FormatFloat(cost, 'f', 4, 64)
+}
+
+// costReportRow is the cost of a group of Sandboxes
+type costReportRow struct {
+	Group     string  `json:"group"`
+	Sandboxes int     `json:"sandboxes"`
+	Reserved  float64 `json:"reserved"`
+	Requested float64 `json:"requested"`
+}
+
+// costReport is the cost of all Sandboxes grouped by owner or cost center
+type costReport struct {
+	GroupBy     string          `json:"groupBy"`
+	GeneratedAt time.Time       `json:"generatedAt"`
+	Rows        []costReportRow `json:"rows"`
+}
+
+// NewCostReportHandler returns a handler that serves the cost of all Sandboxes.
+// The report is grouped by owner unless the groupBy query parameter is set to
+// costCenter, and is served as JSON unless the format query parameter is csv.
+// The cost of a Sandbox with several owners is split evenly between them.
+// The report contains the owners of every Sandbox, so callers must present a
+// bearer token of a user allowed to get the path of the report.
+func NewCostReportHandler(reader client.Reader, authClient client.Client) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if status, err := authorizeNonResourceURL(req, authClient); err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		groupBy := req.URL.Query().Get("groupBy")
+		if groupBy == "" {
+			groupBy = "owner"
+		}
+
+		if groupBy != "owner" && groupBy != "costCenter" {
+			http.Error(w, fmt.Sprintf("unsupported groupBy %q, expected owner or costCenter", groupBy), http.StatusBadRequest)
+			return
+		}
+
+		var sandboxes operatorsv1alpha1.SandboxList
+		if err := reader.List(req.Context(), &sandboxes); err != nil {
+			log.Error(err, "List Sandboxes for cost report")
+			http.Error(w, "list sandboxes", http.StatusInternalServerError)
+			return
+		}
+
+		report := getCostReport(sandboxes.Items, groupBy, time.Now())
+
+		switch req.URL.Query().Get("format") {
+		case "csv":
+			w.Header().Set("Content-Type", "text/csv")
+			if err := writeCostReportCSV(w, report); err != nil {
+				log.Error(err, "Write cost report")
+			}
+		case "", "json":
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(report); err != nil {
+				log.Error(err, "Write cost report")
+			}
+		default:
+			http.Error(w, "unsupported format, expected json or csv", http.StatusBadRequest)
+		}
+	})
+}
+
+func getCostReport(sandboxes []operatorsv1alpha1.Sandbox, groupBy string, now time.Time) costReport {
+	rows := make(map[string]*costReportRow)
+	addCost := func(group string, reserved float64, requested float64) {
+		row, ok := rows[group]
+		if !ok {
+			row = &costReportRow{Group: group}
+			rows[group] = row
+		}
+
+		row.Sandboxes++
+		row.Reserved += reserved
+		row.Requested += requested
+	}
+
+	for _, sandbox := range sandboxes {
+		if sandbox.Status.Cost == nil {
+			continue
+		}
+
+		reserved, requested := getCurrentCost(*sandbox.Status.Cost, now)
+		if groupBy == "costCenter" {
+			addCost(sandbox.Labels[costCenterLabel], reserved, requested)
+			continue
+		}
+
+		if len(sandbox.Spec.Owners) == 0 {
+			addCost("", reserved, requested)
+			continue
+		}
+
+		owners := float64(len(sandbox.Spec.Owners))
+		for _, owner := range sandbox.Spec.Owners {
+			addCost(owner, reserved/owners, requested/owners)
+		}
+	}
+
+	report := costReport{
+		GroupBy:     groupBy,
+		GeneratedAt: now.UTC(),
+		Rows:        []costReportRow{},
+	}
+
+	for _, row := range rows {
+		report.Rows = append(report.Rows, *row)
+	}
+
+	sort.Slice(report.Rows, func(i, j int) bool {
+		return report.Rows[i].Group < report.Rows[j].Group
+	})
+
+	return report
+}
+
+func writeCostReportCSV(w http.ResponseWriter, report costReport) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{report.GroupBy, "sandboxes", "reserved", "requested"}); err != nil {
+		return fmt.Errorf("write header: %w", err)
+	}
+
+	for _, row := range report.Rows {
+		record := []string{
+			row.Group,
+			strconv.Itoa(row.Sandboxes),
+			formatCost(row.Reserved),
+			formatCost(row.Requested),
+		}
+
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("write row: %w", err)
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
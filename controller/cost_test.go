@@ -0,0 +1,163 @@
+// +build !integration
+
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/plexsystems/sandbox-operator/apis"
+	operatorsv1alpha1 "github.com/plexsystems/sandbox-operator/apis/operators/v1alpha1"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPrices_GetHourlyCost(t *testing.T) {
+	prices := Prices{
+		CPUHour:         0.04,
+		MemoryGiBHour:   0.005,
+		StorageGiBMonth: 0.073,
+	}
+
+	resources := corev1.ResourceList{
+		corev1.ResourceRequestsCPU:     resource.MustParse("1"),
+		corev1.ResourceRequestsMemory:  resource.MustParse("2Gi"),
+		corev1.ResourceRequestsStorage: resource.MustParse("40Gi"),
+	}
+
+	expected := "0.0540"
+	if actual := formatCost(prices.getHourlyCost(resources)); actual != expected {
+		t.Errorf("expected hourly cost to be %s but was %s", expected, actual)
+	}
+}
+
+func TestGetCurrentCost_IncludesCostSinceLastAccrued(t *testing.T) {
+	now := time.Now()
+	lastAccrued := metav1.NewTime(now.Add(-2 * time.Hour))
+
+	cost := operatorsv1alpha1.SandboxCost{
+		Reserved:        "1.0000",
+		ReservedHourly:  "0.5000",
+		Requested:       "0.2500",
+		RequestedHourly: "0.1000",
+		LastAccrued:     &lastAccrued,
+	}
+
+	reserved, requested := getCurrentCost(cost, now)
+	if formatCost(reserved) != "2.0000" || formatCost(requested) != "0.4500" {
+		t.Errorf("expected reserved cost 2.0000 and requested cost 0.4500 but got %s and %s", formatCost(reserved), formatCost(requested))
+	}
+}
+
+func TestCostReportHandler_GroupByCostCenter_ServesCSV(t *testing.T) {
+	s := scheme.Scheme
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatalf("add scheme: %v", err)
+	}
+
+	lastAccrued := metav1.NewTime(time.Now())
+	getSandbox := func(name string, costCenter string, reserved string) *operatorsv1alpha1.Sandbox {
+		return &operatorsv1alpha1.Sandbox{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{costCenterLabel: costCenter},
+			},
+			Status: operatorsv1alpha1.SandboxStatus{
+				Cost: &operatorsv1alpha1.SandboxCost{
+					Reserved:    reserved,
+					LastAccrued: &lastAccrued,
+				},
+			},
+		}
+	}
+
+	fakeClient := fake.NewFakeClientWithScheme(s,
+		getSandbox("first", "engineering", "1.5000"),
+		getSandbox("second", "engineering", "2.0000"),
+		getSandbox("third", "finance", "4.0000"),
+	)
+
+	authClient := reviewingClient{
+		tokens:  map[string]string{"admin-token": "admin@bar.com"},
+		allowed: map[string]bool{"admin@bar.com": true},
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/reports/cost?groupBy=costCenter&format=csv", nil)
+	request.Header.Set("Authorization", "Bearer admin-token")
+	response := httptest.NewRecorder()
+	NewCostReportHandler(fakeClient, authClient).ServeHTTP(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected status 200 but got %d: %s", response.Code, response.Body.String())
+	}
+
+	expected := "costCenter,sandboxes,reserved,requested\nengineering,2,3.5000,0.0000\nfinance,1,4.0000,0.0000\n"
+	if actual := response.Body.String(); actual != expected {
+		t.Errorf("expected report:\n%s\nbut got:\n%s", expected, actual)
+	}
+}
+
+func TestCostReportHandler_CallerNotAllowed_Refused(t *testing.T) {
+	authClient := reviewingClient{
+		tokens:  map[string]string{"user-token": "foo@bar.com"},
+		allowed: map[string]bool{},
+	}
+
+	handler := NewCostReportHandler(fake.NewFakeClientWithScheme(scheme.Scheme), authClient)
+
+	tests := []struct {
+		token    string
+		expected int
+	}{
+		{token: "", expected: http.StatusUnauthorized},
+		{token: "unknown-token", expected: http.StatusUnauthorized},
+		{token: "user-token", expected: http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		request := httptest.NewRequest(http.MethodGet, "/reports/cost", nil)
+		if test.token != "" {
+			request.Header.Set("Authorization", "Bearer "+test.token)
+		}
+
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+
+		if response.Code != test.expected {
+			t.Errorf("expected token %q to get status %d but got %d", test.token, test.expected, response.Code)
+		}
+	}
+}
+
+// reviewingClient answers TokenReviews with the users of the known tokens and
+// SubjectAccessReviews with whether the user is allowed
+type reviewingClient struct {
+	client.Client
+
+	tokens  map[string]string
+	allowed map[string]bool
+}
+
+func (c reviewingClient) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+	switch review := obj.(type) {
+	case *authenticationv1.TokenReview:
+		username, ok := c.tokens[review.Spec.Token]
+		review.Status.Authenticated = ok
+		review.Status.User = authenticationv1.UserInfo{Username: username}
+	case *authorizationv1.SubjectAccessReview:
+		review.Status.Allowed = c.allowed[review.Spec.User]
+	}
+
+	return nil
+}
@@ -0,0 +1,351 @@
+package controller
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	operatorsv1alpha1 "github.com/plexsystems/sandbox-operator/apis/operators/v1alpha1"
+)
+
+// defaultEmailTemplate renders the digest of notifications sent to an owner
+const defaultEmailTemplate = `Hello {{.Recipient}},
+
+There {{if eq (len .Notifications) 1}}is 1 update{{else}}are {{len .Notifications}} updates{{end}} about your sandboxes:
+{{range .Notifications}}
+- {{.Time.Format "2006-01-02 15:04 MST"}} {{.Sandbox}} ({{.Namespace}}): {{.Message}}
+{{- end}}
+
+This message was sent by the sandbox-operator.
+`
+
+const (
+	// emailTimeout bounds connecting to the SMTP server and sending one email
+	emailTimeout = 30 * time.Second
+
+	// emailMaxAttempts is how many digests are attempted for an owner before
+	// their pending notifications are dropped
+	emailMaxAttempts = 5
+
+	// emailMaxPending is how many notifications are kept for each owner, the
+	// oldest ones are dropped first
+	emailMaxPending = 100
+)
+
+// EmailConfig configures how notifications are emailed to the owners of Sandboxes
+type EmailConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+
+	// DigestInterval is how often the notifications of each owner are sent as a single email
+	DigestInterval time.Duration
+
+	// TemplatePath is a text/template file used instead of the default email body
+	TemplatePath string
+}
+
+// emailNotification is a single notification in an email digest
+type emailNotification struct {
+	Time      time.Time
+	Type      string
+	Sandbox   string
+	Namespace string
+	Message   string
+}
+
+// emailDigest is the data the email template is rendered with
+type emailDigest struct {
+	Recipient     string
+	Notifications []emailNotification
+}
+
+// EmailNotifier emails notifications to the owners of Sandboxes whose owners
+// are email addresses. Notifications are collected and sent as one digest per
+// owner every digest interval, so that owners are not sent an email for every
+// event.
+type EmailNotifier struct {
+	config   EmailConfig
+	template *template.Template
+	sendMail func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error
+
+	mu       sync.Mutex
+	pending  map[string][]emailNotification
+	attempts map[string]int
+	closed   bool
+	closing  chan struct{}
+	done     chan struct{}
+}
+
+// NewEmailNotifier creates an email notifier using the given SMTP server
+func NewEmailNotifier(config EmailConfig) (*EmailNotifier, error) {
+	if config.Host == "" || config.From == "" {
+		return nil, fmt.Errorf("SMTP host and from address are required")
+	}
+
+	text := defaultEmailTemplate
+	if config.TemplatePath != "" {
+		content, err := ioutil.ReadFile(config.TemplatePath)
+		if err != nil {
+			return nil, fmt.Errorf("read email template: %w", err)
+		}
+
+		text = string(content)
+	}
+
+	body, err := template.New("email").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse email template: %w", err)
+	}
+
+	notifier := EmailNotifier{
+		config:   config,
+		template: body,
+		sendMail: sendMail,
+		pending:  make(map[string][]emailNotification),
+		attempts: make(map[string]int),
+		closing:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	return &notifier, nil
+}
+
+// Add queues the notification for every owner of the Sandbox that is an email
+// address. Notifications added once the notifier is closed are dropped.
+func (e *EmailNotifier) Add(sandbox operatorsv1alpha1.Sandbox, notificationType string, message string) {
+	notification := emailNotification{
+		Time:      time.Now().UTC(),
+		Type:      notificationType,
+		Sandbox:   sandbox.Name,
+		Namespace: getNamespace(sandbox).Name,
+		Message:   message,
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return
+	}
+
+	for _, owner := range sandbox.Spec.Owners {
+		if !isEmailAddress(owner) {
+			continue
+		}
+
+		e.addPending(owner, notification)
+	}
+}
+
+// addPending appends the notifications of the recipient, keeping at most
+// emailMaxPending of them. The caller must hold the lock.
+func (e *EmailNotifier) addPending(recipient string, notifications ...emailNotification) {
+	pending := append(e.pending[recipient], notifications...)
+	if dropped := len(pending) - emailMaxPending; dropped > 0 {
+		log.Info("Dropped the oldest email notifications", "recipient", recipient, "dropped", dropped)
+		pending = pending[dropped:]
+	}
+
+	e.pending[recipient] = pending
+}
+
+// NeedLeaderElection reports that the notifier is run by every replica, so
+// that a replica that loses leadership still sends what it collected
+func (e *EmailNotifier) NeedLeaderElection() bool {
+	return false
+}
+
+// Start sends the pending digests every digest interval until the notifier is
+// closed, and sends what is left when it is closed
+func (e *EmailNotifier) Start(stop <-chan struct{}) error {
+	defer close(e.done)
+
+	ticker := time.NewTicker(e.config.DigestInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			e.Flush()
+		case <-e.closing:
+			e.Flush()
+			return nil
+		}
+	}
+}
+
+// Close stops collecting notifications and returns a channel that is closed
+// once the pending digests have been sent
+func (e *EmailNotifier) Close() <-chan struct{} {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.closed {
+		e.closed = true
+		close(e.closing)
+	}
+
+	return e.done
+}
+
+// Flush sends one email to every owner with pending notifications. Owners that
+// could not be emailed keep their notifications for the next digest, until
+// emailMaxAttempts digests have failed.
+func (e *EmailNotifier) Flush() {
+	e.mu.Lock()
+	pending := e.pending
+	e.pending = make(map[string][]emailNotification)
+	e.mu.Unlock()
+
+	var recipients []string
+	for recipient := range pending {
+		recipients = append(recipients, recipient)
+	}
+
+	sort.Strings(recipients)
+	for _, recipient := range recipients {
+		err := e.send(recipient, pending[recipient])
+
+		e.mu.Lock()
+		if err != nil {
+			e.attempts[recipient]++
+		}
+
+		switch {
+		case err == nil:
+			delete(e.attempts, recipient)
+		case e.attempts[recipient] >= emailMaxAttempts:
+			log.Error(err, "Send email digest, dropping its notifications", "recipient", recipient, "attempts", e.attempts[recipient])
+			delete(e.attempts, recipient)
+		default:
+			log.Error(err, "Send email digest", "recipient", recipient, "attempts", e.attempts[recipient])
+
+			newer := e.pending[recipient]
+			e.pending[recipient] = nil
+			e.addPending(recipient, append(pending[recipient], newer...)...)
+		}
+		e.mu.Unlock()
+	}
+}
+
+func (e *EmailNotifier) send(recipient string, notifications []emailNotification) error {
+	var body bytes.Buffer
+	if err := e.template.Execute(&body, emailDigest{Recipient: recipient, Notifications: notifications}); err != nil {
+		return fmt.Errorf("render email: %w", err)
+	}
+
+	// The subject is encoded as it holds the message of the notification,
+	// which could otherwise add headers to the email
+	subject := fmt.Sprintf("Sandbox %s: %s", notifications[0].Sandbox, notifications[0].Message)
+	if len(notifications) > 1 {
+		subject = fmt.Sprintf("%d updates about your sandboxes", len(notifications))
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", e.config.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", recipient)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.Replace(body.String(), "\n", "\r\n", -1))
+
+	var auth smtp.Auth
+	if e.config.Username != "" {
+		auth = smtp.PlainAuth("", e.config.Username, e.config.Password, e.config.Host)
+	}
+
+	addr := net.JoinHostPort(e.config.Host, strconv.Itoa(e.config.Port))
+	if err := e.sendMail(addr, auth, e.config.From, []string{recipient}, msg.Bytes()); err != nil {
+		return fmt.Errorf("send mail: %w", err)
+	}
+
+	return nil
+}
+
+// sendMail sends an email like smtp.SendMail, but gives up when the SMTP
+// server cannot be reached or does not respond within emailTimeout
+// isEmailAddress reports whether the owner is a bare email address, so that
+// owners cannot add headers to the emails they are sent
+func isEmailAddress(owner string) bool {
+	address, err := mail.ParseAddress(owner)
+	return err == nil && address.Address == owner
+}
+
+func sendMail(addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("split address: %w", err)
+	}
+
+	conn, err := net.DialTimeout("tcp", addr, emailTimeout)
+	if err != nil {
+		return fmt.Errorf("dial: %w", err)
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(emailTimeout)); err != nil {
+		return fmt.Errorf("set deadline: %w", err)
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return fmt.Errorf("new client: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return fmt.Errorf("start TLS: %w", err)
+		}
+	}
+
+	if auth != nil {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return fmt.Errorf("server does not support authentication")
+		}
+
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("authenticate: %w", err)
+		}
+	}
+
+	if err := client.Mail(from); err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+
+	for _, recipient := range to {
+		if err := client.Rcpt(recipient); err != nil {
+			return fmt.Errorf("recipient: %w", err)
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("data: %w", err)
+	}
+
+	if _, err := writer.Write(msg); err != nil {
+		return fmt.Errorf("write message: %w", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("close message: %w", err)
+	}
+
+	return client.Quit()
+}
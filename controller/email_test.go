@@ -0,0 +1,224 @@
+// +build !integration
+
+package controller
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	operatorsv1alpha1 "github.com/plexsystems/sandbox-operator/apis/operators/v1alpha1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEmailNotifier_Flush_SendsDigestPerOwner(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+
+	messages := make(chan string, 10)
+	go serveSMTP(listener, messages)
+
+	host, port, err := net.SplitHostPort(listener.Addr().String())
+	if err != nil {
+		t.Fatalf("split address: %v", err)
+	}
+
+	portNumber, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatalf("parse port: %v", err)
+	}
+
+	config := EmailConfig{
+		Host: host,
+		Port: portNumber,
+		From: "sandbox-operator@bar.com",
+	}
+
+	notifier, err := NewEmailNotifier(config)
+	if err != nil {
+		t.Fatalf("new email notifier: %v", err)
+	}
+
+	sandbox := operatorsv1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+		Spec: operatorsv1alpha1.SandboxSpec{
+			Owners: []string{"foo@bar.com", "group-id"},
+		},
+	}
+
+	notifier.Add(sandbox, NotificationCreated, "The sandbox namespace was created")
+	notifier.Add(sandbox, NotificationQuotaPressure, "The sandbox is running out of quota: pods")
+	notifier.Flush()
+
+	close(messages)
+	var received []string
+	for message := range messages {
+		received = append(received, message)
+	}
+
+	if len(received) != 1 {
+		t.Fatalf("expected a single digest to be sent but found %d emails", len(received))
+	}
+
+	for _, expected := range []string{"To: foo@bar.com", "namespace was created", "running out of quota"} {
+		if !strings.Contains(received[0], expected) {
+			t.Errorf("expected email to contain %q but was:\n%s", expected, received[0])
+		}
+	}
+}
+
+func TestEmailNotifier_Close_DropsDigestsAfterMaxAttempts(t *testing.T) {
+	config := EmailConfig{
+		Host:           "localhost",
+		Port:           587,
+		From:           "sandbox-operator@bar.com",
+		DigestInterval: time.Hour,
+	}
+
+	notifier, err := NewEmailNotifier(config)
+	if err != nil {
+		t.Fatalf("new email notifier: %v", err)
+	}
+
+	attempts := 0
+	notifier.sendMail = func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+		attempts++
+		return fmt.Errorf("server unavailable")
+	}
+
+	sandbox := operatorsv1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+		Spec: operatorsv1alpha1.SandboxSpec{
+			Owners: []string{"foo@bar.com"},
+		},
+	}
+
+	for i := 0; i < emailMaxPending+1; i++ {
+		notifier.Add(sandbox, NotificationCreated, "The sandbox namespace was created")
+	}
+
+	if len(notifier.pending["foo@bar.com"]) != emailMaxPending {
+		t.Errorf("expected %d pending notifications but found %d", emailMaxPending, len(notifier.pending["foo@bar.com"]))
+	}
+
+	for i := 0; i < emailMaxAttempts-1; i++ {
+		notifier.Flush()
+	}
+
+	if len(notifier.pending["foo@bar.com"]) == 0 {
+		t.Fatal("expected the notifications to be kept until the last attempt")
+	}
+
+	go notifier.Start(make(chan struct{}))
+	select {
+	case <-notifier.Close():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the notifier to be drained once closed")
+	}
+
+	if len(notifier.pending["foo@bar.com"]) != 0 || attempts != emailMaxAttempts {
+		t.Errorf("expected the notifications to be dropped after %d attempts but found %d attempts", emailMaxAttempts, attempts)
+	}
+
+	notifier.Add(sandbox, NotificationDeleted, "The sandbox was deleted")
+	if len(notifier.pending) != 0 {
+		t.Errorf("expected no notification to be added once closed but found %v", notifier.pending)
+	}
+}
+
+func TestEmailNotifier_Flush_DoesNotAddHeaders(t *testing.T) {
+	config := EmailConfig{
+		Host: "localhost",
+		Port: 587,
+		From: "sandbox-operator@bar.com",
+	}
+
+	notifier, err := NewEmailNotifier(config)
+	if err != nil {
+		t.Fatalf("new email notifier: %v", err)
+	}
+
+	var sent []string
+	notifier.sendMail = func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+		sent = append(sent, string(msg))
+		return nil
+	}
+
+	sandbox := operatorsv1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+		Spec: operatorsv1alpha1.SandboxSpec{
+			Owners: []string{"foo@bar.com", "baz@bar.com\r\nBcc: evil@bar.com"},
+		},
+	}
+
+	notifier.Add(sandbox, NotificationCreated, "created\r\nBcc: evil@bar.com")
+	notifier.Flush()
+
+	if len(sent) != 1 {
+		t.Fatalf("expected a single email to be sent but found %d", len(sent))
+	}
+
+	headers := strings.SplitN(sent[0], "\r\n\r\n", 2)[0]
+	for _, header := range strings.Split(headers, "\r\n") {
+		if strings.HasPrefix(header, "Bcc:") {
+			t.Errorf("expected no Bcc header but the email had:\n%s", headers)
+		}
+	}
+}
+
+// serveSMTP is a minimal SMTP server that accepts every email and sends the
+// data of each email to messages
+func serveSMTP(listener net.Listener, messages chan<- string) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		text := textproto.NewConn(conn)
+		text.PrintfLine("220 localhost ready")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				break
+			}
+
+			command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			if command == "QUIT" {
+				text.PrintfLine("221 bye")
+				break
+			}
+
+			if command != "DATA" {
+				text.PrintfLine("250 ok")
+				continue
+			}
+
+			text.PrintfLine("354 send data")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				break
+			}
+
+			messages <- string(data)
+			text.PrintfLine("250 ok")
+		}
+
+		text.Close()
+	}
+}
@@ -0,0 +1,190 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+const (
+	// reconcileStuckTimeout is how long a reconcile can run before the
+	// operator is considered wedged. Reconciles requeue rather than wait, so
+	// they normally finish within seconds.
+	reconcileStuckTimeout = 5 * time.Minute
+
+	// identityProviderCheckInterval is how often the identity provider is
+	// pinged, however often its check is run
+	identityProviderCheckInterval = time.Minute
+)
+
+// HealthCheck reports whether a dependency of the operator is healthy, and
+// optionally a detail about its state
+type HealthCheck func(ctx context.Context) (string, error)
+
+// pinger is implemented by subjects clients that can check that their
+// identity provider is reachable
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// NewHealthHandler returns a handler that runs every check and lists their
+// results. It responds with 503 Service Unavailable when any check failed.
+func NewHealthHandler(checks map[string]HealthCheck) http.Handler {
+	const checkTimeout = 5 * time.Second
+
+	var names []string
+	for name := range checks {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx, cancel := context.WithTimeout(req.Context(), checkTimeout)
+		defer cancel()
+
+		healthy := true
+		var body bytes.Buffer
+		for _, name := range names {
+			detail, err := checks[name](ctx)
+			switch {
+			case err != nil:
+				healthy = false
+				fmt.Fprintf(&body, "[-]%s failed: %v\n", name, err)
+			case detail != "":
+				fmt.Fprintf(&body, "[+]%s ok: %s\n", name, detail)
+			default:
+				fmt.Fprintf(&body, "[+]%s ok\n", name)
+			}
+		}
+
+		status := http.StatusOK
+		if !healthy {
+			status = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+		if healthy {
+			body.WriteString("ok\n")
+		}
+
+		w.Write(body.Bytes())
+	})
+}
+
+// LivenessChecks returns the checks that must pass for the operator to be
+// alive: no reconcile has been running for longer than reconcileStuckTimeout
+func (r *ReconcileSandbox) LivenessChecks() map[string]HealthCheck {
+	checks := map[string]HealthCheck{
+		"reconciles": func(ctx context.Context) (string, error) {
+			var oldest time.Time
+			r.reconcileStarts.Range(func(key, value interface{}) bool {
+				if start := value.(time.Time); oldest.IsZero() || start.Before(oldest) {
+					oldest = start
+				}
+
+				return true
+			})
+
+			if !oldest.IsZero() && time.Since(oldest) > reconcileStuckTimeout {
+				return "", fmt.Errorf("a reconcile has been running for %s", time.Since(oldest).Round(time.Second))
+			}
+
+			return fmt.Sprintf("%d in progress", atomic.LoadInt32(&r.inFlight)), nil
+		},
+	}
+
+	return checks
+}
+
+// ReadinessChecks returns the checks that must pass for the operator to be
+// ready: the API server can be reached and the cache has synced. Whether the
+// replica is the leader is reported, but standby replicas are ready as they
+// serve the webhook and ops endpoints. Whether the identity provider can be
+// reached is reported too, but an outage of the identity provider does not
+// make the operator unready, as that would take down the webhook with it.
+func (r *ReconcileSandbox) ReadinessChecks(mgr manager.Manager) (map[string]HealthCheck, error) {
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(mgr.GetConfig())
+	if err != nil {
+		return nil, fmt.Errorf("new discovery client: %w", err)
+	}
+
+	checks := map[string]HealthCheck{
+		"apiserver": func(ctx context.Context) (string, error) {
+			version, err := discoveryClient.ServerVersion()
+			if err != nil {
+				return "", fmt.Errorf("get server version: %w", err)
+			}
+
+			return version.GitVersion, nil
+		},
+		"cache": func(ctx context.Context) (string, error) {
+			if !mgr.GetCache().WaitForCacheSync(ctx.Done()) {
+				return "", fmt.Errorf("cache has not synced")
+			}
+
+			return "", nil
+		},
+		"leader": func(ctx context.Context) (string, error) {
+			if !r.getOptions().LeaderElection || atomic.LoadInt32(&isLeader) == 1 {
+				return "leader", nil
+			}
+
+			return "standby", nil
+		},
+		"identityProvider": r.identityProviderCheck(),
+	}
+
+	return checks, nil
+}
+
+// identityProviderCheck returns a check that reports whether the identity
+// provider can be reached without ever failing. The result of a ping is kept
+// for identityProviderCheckInterval, so that probes do not call the identity
+// provider every time.
+func (r *ReconcileSandbox) identityProviderCheck() HealthCheck {
+	var mu sync.Mutex
+	var checked time.Time
+	var result error
+
+	return func(ctx context.Context) (string, error) {
+		subjectsClient, ok := r.getSubjectsClient().(pinger)
+		if !ok {
+			return "", nil
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if time.Since(checked) >= identityProviderCheckInterval {
+			result = subjectsClient.Ping(ctx)
+			checked = time.Now()
+		}
+
+		if result != nil {
+			return fmt.Sprintf("unreachable: %v", result), nil
+		}
+
+		return "reachable", nil
+	}
+}
+
+// WaitForReconciles waits until the reconciles in progress have finished or
+// the timeout has passed, and reports whether they finished
+func (r *ReconcileSandbox) WaitForReconciles(timeout time.Duration) bool {
+	err := wait.PollImmediate(100*time.Millisecond, timeout, func() (bool, error) {
+		return atomic.LoadInt32(&r.inFlight) == 0, nil
+	})
+
+	return err == nil
+}
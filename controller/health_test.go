@@ -0,0 +1,108 @@
+// +build !integration
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestHealthHandler_FailedCheck_IsUnavailable(t *testing.T) {
+	checks := map[string]HealthCheck{
+		"apiserver": func(ctx context.Context) (string, error) { return "v1.15.7", nil },
+		"cache": func(ctx context.Context) (string, error) {
+			return "", errors.New("cache has not synced")
+		},
+	}
+
+	recorder := httptest.NewRecorder()
+	NewHealthHandler(checks).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 but got %d", recorder.Code)
+	}
+
+	body := recorder.Body.String()
+	if !strings.Contains(body, "[+]apiserver ok: v1.15.7") || !strings.Contains(body, "[-]cache failed: cache has not synced") {
+		t.Errorf("expected the result of every check but got:\n%s", body)
+	}
+
+	recorder = httptest.NewRecorder()
+	NewHealthHandler(nil).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("expected status 200 without checks but got %d", recorder.Code)
+	}
+}
+
+func TestReconcileSandbox_WaitForReconciles_WaitsForInFlight(t *testing.T) {
+	fakeClient := fake.NewFakeClientWithScheme(scheme.Scheme)
+	r := NewReconcileSandbox(fakeClient, fakeClient, scheme.Scheme, DefaultSubjects{}, &record.FakeRecorder{})
+
+	atomic.AddInt32(&r.inFlight, 1)
+	if r.WaitForReconciles(200 * time.Millisecond) {
+		t.Errorf("expected the wait to time out while a reconcile is in progress")
+	}
+
+	time.AfterFunc(100*time.Millisecond, func() { atomic.AddInt32(&r.inFlight, -1) })
+	if !r.WaitForReconciles(5 * time.Second) {
+		t.Errorf("expected the wait to finish once the reconcile finished")
+	}
+}
+
+func TestReconcileSandbox_LivenessChecks_StuckReconcile_Fails(t *testing.T) {
+	fakeClient := fake.NewFakeClientWithScheme(scheme.Scheme)
+	r := NewReconcileSandbox(fakeClient, fakeClient, scheme.Scheme, DefaultSubjects{}, &record.FakeRecorder{})
+
+	r.reconcileStarts.Store("recent", time.Now())
+	if _, err := r.LivenessChecks()["reconciles"](context.TODO()); err != nil {
+		t.Errorf("expected a recent reconcile to be alive but got %v", err)
+	}
+
+	r.reconcileStarts.Store("stuck", time.Now().Add(-reconcileStuckTimeout-time.Minute))
+	if _, err := r.LivenessChecks()["reconciles"](context.TODO()); err == nil {
+		t.Errorf("expected a stuck reconcile to fail the liveness check")
+	}
+}
+
+// unreachableSubjects is a subjects client whose identity provider cannot be reached
+type unreachableSubjects struct {
+	DefaultSubjects
+	pings int
+}
+
+func (u *unreachableSubjects) Ping(ctx context.Context) error {
+	u.pings++
+	return errors.New("graph unreachable")
+}
+
+func TestReconcileSandbox_IdentityProviderCheck_ReportsCachedOutage(t *testing.T) {
+	fakeClient := fake.NewFakeClientWithScheme(scheme.Scheme)
+	subjects := &unreachableSubjects{}
+	r := NewReconcileSandbox(fakeClient, fakeClient, scheme.Scheme, subjects, &record.FakeRecorder{})
+
+	check := r.identityProviderCheck()
+	for i := 0; i < 3; i++ {
+		detail, err := check(context.TODO())
+		if err != nil {
+			t.Fatalf("expected an unreachable identity provider not to fail the check but got %v", err)
+		}
+
+		if detail != "unreachable: graph unreachable" {
+			t.Errorf("expected the outage to be reported but got %q", detail)
+		}
+	}
+
+	if subjects.pings != 1 {
+		t.Errorf("expected the identity provider to be pinged once but found %d pings", subjects.pings)
+	}
+}
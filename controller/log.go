@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var log = logf.Log.WithName("sandbox-controller")
+
+type loggerKey struct{}
+
+// withLogger returns a copy of the context that carries the given logger
+func withLogger(ctx context.Context, logger logr.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// getLogger returns the logger of the current reconcile, which is tagged with the
+// sandbox and reconcile ID, or the controller logger when there is none
+func getLogger(ctx context.Context) logr.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(logr.Logger); ok {
+		return logger
+	}
+
+	return log
+}
+
+// logOperation logs the resources that were created or updated while provisioning
+func logOperation(ctx context.Context, kind string, name string, result controllerutil.OperationResult) {
+	if result == controllerutil.OperationResultNone {
+		return
+	}
+
+	getLogger(ctx).Info("Reconciled resource", "step", kind, "name", name, "operation", string(result))
+}
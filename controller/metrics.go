@@ -0,0 +1,209 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	operatorsv1alpha1 "github.com/plexsystems/sandbox-operator/apis/operators/v1alpha1"
+
+	"github.com/prometheus/client_golang/prometheus"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	stepDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "sandbox_operator_reconcile_step_duration_seconds",
+		Help: "Time taken by each step of provisioning or tearing down a sandbox",
+	}, []string{"step"})
+
+	stepErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sandbox_operator_reconcile_step_errors_total",
+		Help: "Number of times each step of provisioning or tearing down a sandbox failed",
+	}, []string{"step"})
+
+	reconcileErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "sandbox_operator_reconcile_errors_total",
+		Help: "Number of reconciles of a sandbox that failed and were retried with backoff",
+	})
+
+	ownerResolutionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "sandbox_operator_owner_resolution_duration_seconds",
+		Help: "Time taken to resolve the owners of a sandbox into subjects",
+	}, []string{"backend"})
+
+	ownerResolutionFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sandbox_operator_owner_resolution_failures_total",
+		Help: "Number of times the owners of a sandbox could not be resolved into subjects",
+	}, []string{"backend"})
+
+	ownersUnresolved = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sandbox_operator_owners_unresolved_total",
+		Help: "Number of owners that could not be found by the identity provider",
+	}, []string{"backend"})
+
+	pullSecretSynced = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "sandbox_operator_pull_secret_synced",
+		Help: "Whether a pull secret was copied into a sandbox on its last reconcile",
+	}, []string{"sandbox", "secret"})
+
+	leader = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "sandbox_operator_leader",
+		Help: "Whether this replica of the operator is the leader",
+	})
+)
+
+var sandboxesDesc = prometheus.NewDesc(
+	"sandbox_operator_sandboxes",
+	"Number of sandboxes by size and phase",
+	[]string{"size", "phase"},
+	nil,
+)
+
+var sandboxCostDesc = prometheus.NewDesc(
+	"sandbox_operator_sandbox_cost",
+	"Cost of a sandbox over its lifetime, by reserved quota or requested resources",
+	[]string{"sandbox", "cost_center", "type"},
+	nil,
+)
+
+var sandboxHourlyCostDesc = prometheus.NewDesc(
+	"sandbox_operator_sandbox_hourly_cost",
+	"Current hourly cost of a sandbox, by reserved quota or requested resources",
+	[]string{"sandbox", "cost_center", "type"},
+	nil,
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		stepDuration,
+		stepErrors,
+		reconcileErrors,
+		ownerResolutionDuration,
+		ownerResolutionFailures,
+		ownersUnresolved,
+		pullSecretSynced,
+		leader,
+	)
+}
+
+// isLeader is 1 while this replica is the leader
+var isLeader int32
+
+// recordLeadership sets the leader metric while this replica is the leader.
+// It is run by the manager once leadership has been acquired.
+func recordLeadership(stop <-chan struct{}) error {
+	atomic.StoreInt32(&isLeader, 1)
+	leader.Set(1)
+	<-stop
+	leader.Set(0)
+	atomic.StoreInt32(&isLeader, 0)
+
+	return nil
+}
+
+// observeStep records the duration of a provisioning or teardown step and
+// whether it failed
+func observeStep(step string, start time.Time, err error) {
+	stepDuration.WithLabelValues(step).Observe(time.Since(start).Seconds())
+	if err != nil {
+		stepErrors.WithLabelValues(step).Inc()
+	}
+}
+
+// sandboxCollector counts the sandboxes in the cache each time metrics are scraped
+type sandboxCollector struct {
+	client client.Client
+}
+
+// Describe implements prometheus.Collector
+func (c sandboxCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- sandboxesDesc
+	ch <- sandboxCostDesc
+	ch <- sandboxHourlyCostDesc
+}
+
+// Collect implements prometheus.Collector
+func (c sandboxCollector) Collect(ch chan<- prometheus.Metric) {
+	var sandboxes operatorsv1alpha1.SandboxList
+	if err := c.client.List(context.Background(), &sandboxes); err != nil {
+		log.Error(err, "List Sandboxes for metrics")
+		return
+	}
+
+	now := time.Now()
+	counts := make(map[[2]string]int)
+	for _, sandbox := range sandboxes.Items {
+		collectCost(ch, sandbox, now)
+
+		phase := string(sandbox.Status.Phase)
+		if phase == "" {
+			phase = "Pending"
+		}
+
+		counts[[2]string{GetAppliedSize(sandbox), phase}]++
+	}
+
+	for labels, count := range counts {
+		ch <- prometheus.MustNewConstMetric(sandboxesDesc, prometheus.GaugeValue, float64(count), labels[0], labels[1])
+	}
+}
+
+func collectCost(ch chan<- prometheus.Metric, sandbox operatorsv1alpha1.Sandbox, now time.Time) {
+	if sandbox.Status.Cost == nil {
+		return
+	}
+
+	costCenter := sandbox.Labels[costCenterLabel]
+	reserved, requested := getCurrentCost(*sandbox.Status.Cost, now)
+
+	ch <- prometheus.MustNewConstMetric(sandboxCostDesc, prometheus.GaugeValue, reserved, sandbox.Name, costCenter, "reserved")
+	ch <- prometheus.MustNewConstMetric(sandboxCostDesc, prometheus.GaugeValue, requested, sandbox.Name, costCenter, "requested")
+	ch <- prometheus.MustNewConstMetric(sandboxHourlyCostDesc, prometheus.GaugeValue, parseCost(sandbox.Status.Cost.ReservedHourly), sandbox.Name, costCenter, "reserved")
+	ch <- prometheus.MustNewConstMetric(sandboxHourlyCostDesc, prometheus.GaugeValue, parseCost(sandbox.Status.Cost.RequestedHourly), sandbox.Name, costCenter, "requested")
+}
+
+// getSize returns the size requested in the spec of the sandbox
+func getSize(sandbox operatorsv1alpha1.Sandbox) string {
+	if strings.EqualFold(sandbox.Spec.Size, "large") {
+		return "large"
+	}
+
+	return "small"
+}
+
+// instrumentedSubjects records the latency and failures of a SubjectsClient
+type instrumentedSubjects struct {
+	backend        string
+	subjectsClient SubjectsClient
+}
+
+// Ping checks that the identity provider of the wrapped client can be reached
+func (i instrumentedSubjects) Ping(ctx context.Context) error {
+	subjectsClient, ok := i.subjectsClient.(pinger)
+	if !ok {
+		return nil
+	}
+
+	return subjectsClient.Ping(ctx)
+}
+
+// Subjects resolves the users with the wrapped client and records how it went
+func (i instrumentedSubjects) Subjects(ctx context.Context, users []string) ([]rbacv1.Subject, error) {
+	start := time.Now()
+	subjects, err := i.subjectsClient.Subjects(ctx, users)
+	ownerResolutionDuration.WithLabelValues(i.backend).Observe(time.Since(start).Seconds())
+	if err != nil {
+		ownerResolutionFailures.WithLabelValues(i.backend).Inc()
+		return nil, err
+	}
+
+	if len(subjects) < len(users) {
+		ownersUnresolved.WithLabelValues(i.backend).Add(float64(len(users) - len(subjects)))
+	}
+
+	return subjects, nil
+}
@@ -0,0 +1,65 @@
+// +build !integration
+
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/plexsystems/sandbox-operator/apis"
+	operatorsv1alpha1 "github.com/plexsystems/sandbox-operator/apis/operators/v1alpha1"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestInstrumentedSubjects_UnresolvedOwners_CountsOwners(t *testing.T) {
+	subjects := instrumentedSubjects{
+		backend:        "unresolved",
+		subjectsClient: unresolvedSubjects{},
+	}
+
+	if _, err := subjects.Subjects(context.TODO(), []string{"missing@plex.dev", "found@plex.dev"}); err != nil {
+		t.Fatalf("get subjects: %v", err)
+	}
+
+	if unresolved := testutil.ToFloat64(ownersUnresolved.WithLabelValues("unresolved")); unresolved != 1 {
+		t.Errorf("expected 1 unresolved owner but found %v", unresolved)
+	}
+}
+
+func TestSandboxCollector_CountsSandboxesBySizeAndPhase(t *testing.T) {
+	s := scheme.Scheme
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatalf("add scheme: %v", err)
+	}
+
+	fakeClient := fake.NewFakeClientWithScheme(s,
+		&operatorsv1alpha1.Sandbox{
+			ObjectMeta: metav1.ObjectMeta{Name: "first"},
+			Status:     operatorsv1alpha1.SandboxStatus{Phase: operatorsv1alpha1.SandboxPhaseActive},
+		},
+		&operatorsv1alpha1.Sandbox{
+			ObjectMeta: metav1.ObjectMeta{Name: "second"},
+			Status:     operatorsv1alpha1.SandboxStatus{Phase: operatorsv1alpha1.SandboxPhaseActive},
+		},
+		&operatorsv1alpha1.Sandbox{
+			ObjectMeta: metav1.ObjectMeta{Name: "third"},
+			Spec:       operatorsv1alpha1.SandboxSpec{Size: "large"},
+		},
+	)
+
+	expected := `
+		# HELP sandbox_operator_sandboxes Number of sandboxes by size and phase
+		# TYPE sandbox_operator_sandboxes gauge
+		sandbox_operator_sandboxes{phase="Active",size="small"} 2
+		sandbox_operator_sandboxes{phase="Pending",size="large"} 1
+	`
+
+	if err := testutil.CollectAndCompare(sandboxCollector{client: fakeClient}, strings.NewReader(expected)); err != nil {
+		t.Errorf("unexpected sandbox metrics: %v", err)
+	}
+}
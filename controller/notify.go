@@ -0,0 +1,308 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/go-logr/logr"
+	operatorsv1alpha1 "github.com/plexsystems/sandbox-operator/apis/operators/v1alpha1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// Notification types sent over the lifecycle of a Sandbox
+const (
+	NotificationCreated         = "dev.plex.sandbox.created"
+	NotificationProvisionFailed = "dev.plex.sandbox.provisionFailed"
+	NotificationQuotaPressure   = "dev.plex.sandbox.quotaPressure"
+	NotificationBudgetWarning   = "dev.plex.sandbox.budgetWarning"
+	NotificationHibernated      = "dev.plex.sandbox.hibernated"
+	NotificationRejected        = "dev.plex.sandbox.rejected"
+	NotificationDeleted         = "dev.plex.sandbox.deleted"
+)
+
+// Payload formats of a notification endpoint
+const (
+	formatCloudEvents = "cloudevents"
+	formatSlack       = "slack"
+	formatTeams       = "teams"
+)
+
+// NotificationConfig configures where notifications are sent
+type NotificationConfig struct {
+	Endpoints []NotificationEndpoint `json:"endpoints"`
+}
+
+// NotificationEndpoint is an HTTP endpoint that notifications are posted to
+type NotificationEndpoint struct {
+	URL string `json:"url"`
+
+	// Format is cloudevents, slack or teams. Defaults to cloudevents.
+	Format string `json:"format,omitempty"`
+
+	// Types limits the notifications sent to the endpoint. All types are sent when empty.
+	Types []string `json:"types,omitempty"`
+
+	// Selector limits the notifications sent to the endpoint to Sandboxes with matching labels
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+}
+
+// LoadNotificationConfig reads the notification config from a YAML or JSON file
+func LoadNotificationConfig(path string) (NotificationConfig, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return NotificationConfig{}, fmt.Errorf("open notification config: %w", err)
+	}
+	defer file.Close()
+
+	var config NotificationConfig
+	if err := yaml.NewYAMLOrJSONDecoder(file, 4096).Decode(&config); err != nil {
+		return NotificationConfig{}, fmt.Errorf("decode notification config: %w", err)
+	}
+
+	return config, nil
+}
+
+// notificationEndpoint is a NotificationEndpoint with its selector parsed
+type notificationEndpoint struct {
+	NotificationEndpoint
+	selector labels.Selector
+}
+
+// notificationQueueSize is how many notifications can wait to be sent
+const notificationQueueSize = 1000
+
+// queuedNotification is a notification waiting to be sent in the background
+type queuedNotification struct {
+	sandbox          operatorsv1alpha1.Sandbox
+	notificationType string
+	message          string
+	logger           logr.Logger
+}
+
+// Notifier posts lifecycle notifications of Sandboxes to HTTP endpoints,
+// retrying failed posts with exponential backoff. Notifications are queued
+// and sent one at a time in the background.
+type Notifier struct {
+	*sendQueue
+
+	endpoints   []notificationEndpoint
+	client      *http.Client
+	maxAttempts int
+	backoff     time.Duration
+}
+
+// NewNotifier creates a notifier that sends notifications to the configured endpoints
+func NewNotifier(config NotificationConfig) (*Notifier, error) {
+	notifier := Notifier{
+		client:      &http.Client{Timeout: 10 * time.Second},
+		maxAttempts: 5,
+		backoff:     time.Second,
+	}
+
+	notifier.sendQueue = newSendQueue(notificationQueueSize, func(item interface{}) {
+		queued := item.(queuedNotification)
+		if err := notifier.Notify(context.Background(), queued.sandbox, queued.notificationType, queued.message); err != nil {
+			queued.logger.Error(err, "Send notification", "type", queued.notificationType)
+		}
+	})
+
+	for _, endpoint := range config.Endpoints {
+		if endpoint.URL == "" {
+			return nil, fmt.Errorf("notification endpoint is missing a url")
+		}
+
+		switch endpoint.Format {
+		case "":
+			endpoint.Format = formatCloudEvents
+		case formatCloudEvents, formatSlack, formatTeams:
+		default:
+			return nil, fmt.Errorf("unsupported format %q for %s, expected %s, %s or %s", endpoint.Format, endpoint.URL, formatCloudEvents, formatSlack, formatTeams)
+		}
+
+		selector := labels.Everything()
+		if endpoint.Selector != nil {
+			var err error
+			selector, err = metav1.LabelSelectorAsSelector(endpoint.Selector)
+			if err != nil {
+				return nil, fmt.Errorf("parse selector for %s: %w", endpoint.URL, err)
+			}
+		}
+
+		notifier.endpoints = append(notifier.endpoints, notificationEndpoint{
+			NotificationEndpoint: endpoint,
+			selector:             selector,
+		})
+	}
+
+	return &notifier, nil
+}
+
+// cloudEvent is a notification in the structured JSON format of CloudEvents 1.0
+type cloudEvent struct {
+	SpecVersion     string           `json:"specversion"`
+	ID              string           `json:"id"`
+	Source          string           `json:"source"`
+	Type            string           `json:"type"`
+	Subject         string           `json:"subject"`
+	Time            time.Time        `json:"time"`
+	DataContentType string           `json:"datacontenttype"`
+	Data            notificationData `json:"data"`
+}
+
+// notificationData describes the Sandbox a notification is about
+type notificationData struct {
+	Sandbox   string            `json:"sandbox"`
+	Namespace string            `json:"namespace"`
+	Owners    []string          `json:"owners,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Message   string            `json:"message"`
+}
+
+// Notify sends the notification to every endpoint that accepts its type and
+// the labels of the Sandbox, and returns the first endpoint that failed
+func (n *Notifier) Notify(ctx context.Context, sandbox operatorsv1alpha1.Sandbox, notificationType string, message string) error {
+	event := cloudEvent{
+		SpecVersion:     "1.0",
+		ID:              string(uuid.NewUUID()),
+		Source:          "/apis/operators.plex.dev/v1alpha1/sandboxes/" + sandbox.Name,
+		Type:            notificationType,
+		Subject:         sandbox.Name,
+		Time:            time.Now().UTC(),
+		DataContentType: "application/json",
+		Data: notificationData{
+			Sandbox:   sandbox.Name,
+			Namespace: getNamespace(sandbox).Name,
+			Owners:    sandbox.Spec.Owners,
+			Labels:    sandbox.Labels,
+			Message:   message,
+		},
+	}
+
+	var firstErr error
+	for _, endpoint := range n.endpoints {
+		if len(endpoint.Types) > 0 && !ContainsString(endpoint.Types, notificationType) {
+			continue
+		}
+
+		if !endpoint.selector.Matches(labels.Set(sandbox.Labels)) {
+			continue
+		}
+
+		if err := n.send(ctx, endpoint.NotificationEndpoint, event); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("notify %s: %w", endpoint.URL, err)
+		}
+	}
+
+	return firstErr
+}
+
+// send posts the event to the endpoint in its format, retrying with exponential backoff
+func (n *Notifier) send(ctx context.Context, endpoint NotificationEndpoint, event cloudEvent) error {
+	contentType, body, err := getNotificationPayload(endpoint.Format, event)
+	if err != nil {
+		return fmt.Errorf("get payload: %w", err)
+	}
+
+	backoff := n.backoff
+	for attempt := 1; ; attempt++ {
+		err = n.post(ctx, endpoint.URL, contentType, body)
+		if err == nil || attempt >= n.maxAttempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+	}
+}
+
+func (n *Notifier) post(ctx context.Context, url string, contentType string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("new request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	resp, err := n.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("post: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return nil
+}
+
+// getNotificationPayload returns the content type and body of the event in the given format
+func getNotificationPayload(format string, event cloudEvent) (string, []byte, error) {
+	title := fmt.Sprintf("Sandbox %s: %s", event.Subject, event.Type)
+
+	var contentType string
+	var payload interface{}
+	switch format {
+	case formatSlack:
+		contentType = "application/json"
+		payload = map[string]string{
+			"text": fmt.Sprintf("*%s*\n%s", title, event.Data.Message),
+		}
+	case formatTeams:
+		contentType = "application/json"
+		payload = map[string]string{
+			"@type":    "MessageCard",
+			"@context": "https://schema.org/extensions",
+			"summary":  title,
+			"title":    title,
+			"text":     event.Data.Message,
+		}
+	default:
+		contentType = "application/cloudevents+json"
+		payload = event
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", nil, fmt.Errorf("marshal %s payload: %w", format, err)
+	}
+
+	return contentType, body, nil
+}
+
+// notify queues a notification about the Sandbox to be sent in the background,
+// so that retrying unavailable endpoints does not hold up the reconcile, and
+// queues it for the next email digest to its owners
+func (r *ReconcileSandbox) notify(ctx context.Context, sandbox operatorsv1alpha1.Sandbox, notificationType string, message string) {
+	if r.emailNotifier != nil {
+		r.emailNotifier.Add(sandbox, notificationType, message)
+	}
+
+	if r.notifier == nil {
+		return
+	}
+
+	queued := queuedNotification{
+		sandbox:          sandbox,
+		notificationType: notificationType,
+		message:          message,
+		logger:           getLogger(ctx),
+	}
+
+	if !r.notifier.add(queued) {
+		getLogger(ctx).Info("Dropped notification, the queue is full or closed", "type", notificationType)
+	}
+}
@@ -0,0 +1,169 @@
+// +build !integration
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	operatorsv1alpha1 "github.com/plexsystems/sandbox-operator/apis/operators/v1alpha1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNotifier_Notify_SendsMatchingNotificationsWithRetries(t *testing.T) {
+	var mu sync.Mutex
+	var events []cloudEvent
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		if contentType := req.Header.Get("Content-Type"); contentType != "application/cloudevents+json" {
+			t.Errorf("expected a CloudEvents content type but found %s", contentType)
+		}
+
+		var event cloudEvent
+		if err := json.NewDecoder(req.Body).Decode(&event); err != nil {
+			t.Errorf("decode event: %v", err)
+		}
+
+		events = append(events, event)
+	}))
+	defer server.Close()
+
+	config := NotificationConfig{
+		Endpoints: []NotificationEndpoint{
+			{
+				URL:   server.URL,
+				Types: []string{NotificationCreated},
+				Selector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"team": "a"},
+				},
+			},
+		},
+	}
+
+	notifier, err := NewNotifier(config)
+	if err != nil {
+		t.Fatalf("new notifier: %v", err)
+	}
+
+	notifier.backoff = time.Millisecond
+
+	sandbox := operatorsv1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "test",
+			Labels: map[string]string{"team": "a"},
+		},
+	}
+
+	otherSandbox := operatorsv1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "other",
+			Labels: map[string]string{"team": "b"},
+		},
+	}
+
+	notifications := []struct {
+		sandbox          operatorsv1alpha1.Sandbox
+		notificationType string
+	}{
+		{sandbox, NotificationCreated},
+		{sandbox, NotificationDeleted},
+		{otherSandbox, NotificationCreated},
+	}
+
+	for _, notification := range notifications {
+		if err := notifier.Notify(context.TODO(), notification.sandbox, notification.notificationType, "message"); err != nil {
+			t.Fatalf("notify: %v", err)
+		}
+	}
+
+	if len(events) != 1 {
+		t.Fatalf("expected 1 notification but found %d: %v", len(events), events)
+	}
+
+	if events[0].Type != NotificationCreated || events[0].Subject != "test" || events[0].SpecVersion != "1.0" {
+		t.Errorf("expected a created CloudEvent for test but found %v", events[0])
+	}
+
+	if attempts != 2 {
+		t.Errorf("expected the failed notification to be retried once but found %d attempts", attempts)
+	}
+}
+
+func TestReconcileSandbox_Notify_SendsQueuedNotificationsOnClose(t *testing.T) {
+	var mu sync.Mutex
+	sent := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		sent++
+	}))
+	defer server.Close()
+
+	notifier, err := NewNotifier(NotificationConfig{Endpoints: []NotificationEndpoint{{URL: server.URL}}})
+	if err != nil {
+		t.Fatalf("new notifier: %v", err)
+	}
+
+	reconciler := ReconcileSandbox{notifier: notifier, senders: []sender{notifier}}
+	go notifier.Start(make(chan struct{}))
+
+	sandbox := operatorsv1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+	}
+
+	reconciler.notify(context.TODO(), sandbox, NotificationCreated, "message")
+	reconciler.notify(context.TODO(), sandbox, NotificationDeleted, "message")
+
+	if !reconciler.Close(5 * time.Second) {
+		t.Fatal("expected the queued notifications to be sent before the timeout")
+	}
+
+	if sent != 2 {
+		t.Errorf("expected 2 notifications to be sent but found %d", sent)
+	}
+
+	reconciler.notify(context.TODO(), sandbox, NotificationDeleted, "message")
+	if sent != 2 {
+		t.Errorf("expected no notification to be queued once closed but found %d", sent)
+	}
+}
+
+func TestGetNotificationPayload_Slack_SendsText(t *testing.T) {
+	event := cloudEvent{
+		Type:    NotificationDeleted,
+		Subject: "test",
+		Data:    notificationData{Message: "Deleted namespace sandbox-test"},
+	}
+
+	contentType, body, err := getNotificationPayload(formatSlack, event)
+	if err != nil {
+		t.Fatalf("get payload: %v", err)
+	}
+
+	var payload map[string]string
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("unmarshal payload: %v", err)
+	}
+
+	if contentType != "application/json" || payload["text"] == "" {
+		t.Errorf("expected a Slack payload with text but found %s %s", contentType, body)
+	}
+}
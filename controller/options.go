@@ -0,0 +1,552 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Options configures how Sandboxes are reconciled
+type Options struct {
+	// MaxConcurrentReconciles is the number of Sandboxes that can be reconciled at the same time
+	MaxConcurrentReconciles int
+
+	// BaseBackoff and MaxBackoff bound the exponential backoff of a Sandbox that fails to reconcile
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+
+	// RateLimitQPS and RateLimitBurst configure the token bucket shared by all reconciles
+	RateLimitQPS   float64
+	RateLimitBurst int
+
+	// ResyncPeriod is how often every Sandbox is reconciled when nothing has changed.
+	// Each Sandbox is requeued with up to 10% jitter so resyncs are spread out.
+	ResyncPeriod time.Duration
+
+	// QuotaPressureThreshold is the percentage of a quota resource that can be used
+	// before the QuotaPressure condition of the Sandbox is set
+	QuotaPressureThreshold int64
+
+	// Prices is the cost model used to accrue the cost of each Sandbox
+	Prices Prices
+
+	// Budgets is the default budget of a Sandbox by size, used when the Sandbox does not set one
+	Budgets map[string]float64
+
+	// BudgetWarningThreshold is the percentage of its budget a Sandbox can spend
+	// before its owners are warned
+	BudgetWarningThreshold float64
+
+	// ResizeApprovalSize is the smallest size that a Sandbox can only be resized
+	// to once a SandboxResizeRequest has been approved. Empty disables approvals.
+	ResizeApprovalSize string
+
+	// CreationApprovalGroup is the group whose members approve new Sandboxes
+	// before they are provisioned. Empty disables approvals.
+	CreationApprovalGroup string
+
+	// BudgetAdminGroup is the group whose members may set the budget of
+	// Sandboxes. Empty lets anyone who can edit a Sandbox set its budget.
+	BudgetAdminGroup string
+
+	// RejectionGracePeriod is how long a rejected Sandbox is kept before it is deleted
+	RejectionGracePeriod time.Duration
+
+	// WebhookEnabled serves the Sandbox admission webhook, which records the
+	// users that create and change Sandboxes. It is always served when
+	// creation approvals or budget admins are enabled.
+	WebhookEnabled bool
+
+	// AuditLogPath is the file the audit trail is appended to, or - for stdout
+	AuditLogPath string
+
+	// AuditWebhookURL is an HTTP endpoint every audit record is posted to
+	AuditWebhookURL string
+
+	// NotificationConfigPath is the file that configures where lifecycle
+	// notifications are sent. Empty disables notifications.
+	NotificationConfigPath string
+
+	// Email configures the SMTP server notifications are emailed to owners
+	// with. Empty Host disables emails.
+	Email EmailConfig
+
+	// LeaderElection makes replicas of the operator elect a leader, so that
+	// only one of them reconciles Sandboxes at a time
+	LeaderElection bool
+
+	// LeaseDuration is how long standby replicas wait before taking over
+	// leadership from a leader that stopped renewing its lease
+	LeaseDuration time.Duration
+
+	// RenewDeadline is how long the leader keeps trying to renew its lease
+	// before it gives up leadership
+	RenewDeadline time.Duration
+
+	// ConfigPath is the operator configuration file, which is reloaded when it changes
+	ConfigPath string
+
+	// IdentityProvider resolves the owners of Sandboxes into subjects, either
+	// default or azure
+	IdentityProvider string
+
+	// AzureTenantID is the tenant the azure identity provider looks owners up in
+	AzureTenantID string
+
+	// PullSecretNames are the pull secrets copied from PullSecretNamespace into every Sandbox
+	PullSecretNames     []string
+	PullSecretNamespace string
+
+	// ScaleDownTimeout is how long a teardown waits for the pods of scaled
+	// down workloads to stop before it deletes the namespace anyway
+	ScaleDownTimeout time.Duration
+
+	// NamespaceStuckTimeout is how long a namespace may take to terminate
+	// before the resources blocking it are reported on the Sandbox
+	NamespaceStuckTimeout time.Duration
+
+	// APIAddress is the address the Sandbox REST API listens on. Empty
+	// disables the API.
+	APIAddress string
+
+	// APITrustedHeaders authenticates API callers from the X-Remote-User and
+	// X-Remote-Group headers set by an authenticating proxy
+	APITrustedHeaders bool
+
+	// APIClientCAFile is the CA the client certificate of the authenticating
+	// proxy must be signed by for its headers to be trusted
+	APIClientCAFile string
+
+	// APIAllowedNames are the common names the client certificate of the
+	// authenticating proxy may have, any name is allowed when empty
+	APIAllowedNames []string
+
+	// APITLSCertFile and APITLSKeyFile serve the API over TLS when both are set
+	APITLSCertFile string
+	APITLSKeyFile  string
+
+	// APIInsecure allows the API to be served without TLS
+	APIInsecure bool
+}
+
+// Prices configures what the resources of a Sandbox cost
+type Prices struct {
+	CPUHour         float64
+	MemoryGiBHour   float64
+	StorageGiBMonth float64
+
+	// IncludeRequested also accrues the cost of the resources requested by
+	// workloads, in addition to the resources reserved by the ResourceQuota
+	IncludeRequested bool
+}
+
+// enabled reports whether any resource has a price
+func (p Prices) enabled() bool {
+	return p.CPUHour > 0 || p.MemoryGiBHour > 0 || p.StorageGiBMonth > 0
+}
+
+// DefaultOptions returns the options used when nothing has been configured
+func DefaultOptions() Options {
+	options := Options{
+		MaxConcurrentReconciles: 1,
+		BaseBackoff:             5 * time.Millisecond,
+		MaxBackoff:              1000 * time.Second,
+		RateLimitQPS:            10,
+		RateLimitBurst:          100,
+		ResyncPeriod:            10 * time.Hour,
+		QuotaPressureThreshold:  90,
+		Budgets:                 make(map[string]float64),
+		BudgetWarningThreshold:  80,
+		RejectionGracePeriod:    24 * time.Hour,
+		Email: EmailConfig{
+			Port:           587,
+			DigestInterval: time.Hour,
+		},
+		LeaderElection:        true,
+		LeaseDuration:         15 * time.Second,
+		RenewDeadline:         10 * time.Second,
+		IdentityProvider:      identityProviderDefault,
+		PullSecretNamespace:   "default",
+		ScaleDownTimeout:      2 * time.Minute,
+		NamespaceStuckTimeout: 2 * time.Minute,
+	}
+
+	return options
+}
+
+// GetOptions returns the default options overridden by the config file set in
+// OPERATOR_CONFIG, and then by any options set in the environment
+func GetOptions() (Options, error) {
+	options := DefaultOptions()
+
+	if path := os.Getenv("OPERATOR_CONFIG"); path != "" {
+		options.ConfigPath = path
+
+		config, err := LoadConfig(path)
+		if err != nil {
+			return Options{}, fmt.Errorf("load config: %w", err)
+		}
+
+		if err := config.apply(&options); err != nil {
+			return Options{}, fmt.Errorf("apply config %s: %w", path, err)
+		}
+	}
+
+	if value := os.Getenv("MAX_CONCURRENT_RECONCILES"); value != "" {
+		maxConcurrentReconciles, err := strconv.Atoi(value)
+		if err != nil || maxConcurrentReconciles < 1 {
+			return Options{}, fmt.Errorf("MAX_CONCURRENT_RECONCILES must be a positive integer: %s", value)
+		}
+
+		options.MaxConcurrentReconciles = maxConcurrentReconciles
+	}
+
+	if value := os.Getenv("RECONCILE_BASE_BACKOFF"); value != "" {
+		baseBackoff, err := time.ParseDuration(value)
+		if err != nil {
+			return Options{}, fmt.Errorf("parse RECONCILE_BASE_BACKOFF: %w", err)
+		}
+
+		options.BaseBackoff = baseBackoff
+	}
+
+	if value := os.Getenv("RECONCILE_MAX_BACKOFF"); value != "" {
+		maxBackoff, err := time.ParseDuration(value)
+		if err != nil {
+			return Options{}, fmt.Errorf("parse RECONCILE_MAX_BACKOFF: %w", err)
+		}
+
+		options.MaxBackoff = maxBackoff
+	}
+
+	if value := os.Getenv("RECONCILE_QPS"); value != "" {
+		rateLimitQPS, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return Options{}, fmt.Errorf("parse RECONCILE_QPS: %w", err)
+		}
+
+		options.RateLimitQPS = rateLimitQPS
+	}
+
+	if value := os.Getenv("RECONCILE_BURST"); value != "" {
+		rateLimitBurst, err := strconv.Atoi(value)
+		if err != nil {
+			return Options{}, fmt.Errorf("parse RECONCILE_BURST: %w", err)
+		}
+
+		options.RateLimitBurst = rateLimitBurst
+	}
+
+	if value := os.Getenv("RESYNC_PERIOD"); value != "" {
+		resyncPeriod, err := time.ParseDuration(value)
+		if err != nil {
+			return Options{}, fmt.Errorf("parse RESYNC_PERIOD: %w", err)
+		}
+
+		options.ResyncPeriod = resyncPeriod
+	}
+
+	if value := os.Getenv("QUOTA_PRESSURE_THRESHOLD"); value != "" {
+		quotaPressureThreshold, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || quotaPressureThreshold < 1 || quotaPressureThreshold > 100 {
+			return Options{}, fmt.Errorf("QUOTA_PRESSURE_THRESHOLD must be a percentage between 1 and 100: %s", value)
+		}
+
+		options.QuotaPressureThreshold = quotaPressureThreshold
+	}
+
+	prices := map[string]*float64{
+		"COST_CPU_HOUR":          &options.Prices.CPUHour,
+		"COST_MEMORY_GIB_HOUR":   &options.Prices.MemoryGiBHour,
+		"COST_STORAGE_GIB_MONTH": &options.Prices.StorageGiBMonth,
+	}
+
+	for name, price := range prices {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed < 0 {
+			return Options{}, fmt.Errorf("%s must be a non-negative number: %s", name, value)
+		}
+
+		*price = parsed
+	}
+
+	budgets := map[string]string{
+		"small": "BUDGET_SMALL",
+		"large": "BUDGET_LARGE",
+	}
+
+	for size, name := range budgets {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+
+		budget, err := strconv.ParseFloat(value, 64)
+		if err != nil || budget <= 0 {
+			return Options{}, fmt.Errorf("%s must be a positive number: %s", name, value)
+		}
+
+		options.Budgets[size] = budget
+	}
+
+	if value := os.Getenv("BUDGET_WARNING_THRESHOLD"); value != "" {
+		budgetWarningThreshold, err := strconv.ParseFloat(value, 64)
+		if err != nil || budgetWarningThreshold <= 0 || budgetWarningThreshold > 100 {
+			return Options{}, fmt.Errorf("BUDGET_WARNING_THRESHOLD must be a percentage between 1 and 100: %s", value)
+		}
+
+		options.BudgetWarningThreshold = budgetWarningThreshold
+	}
+
+	if value := os.Getenv("COST_INCLUDE_REQUESTED"); value != "" {
+		includeRequested, err := strconv.ParseBool(value)
+		if err != nil {
+			return Options{}, fmt.Errorf("parse COST_INCLUDE_REQUESTED: %w", err)
+		}
+
+		options.Prices.IncludeRequested = includeRequested
+	}
+
+	if value := os.Getenv("RESIZE_APPROVAL_SIZE"); value != "" {
+		if !ContainsString(Sizes, value) {
+			return Options{}, fmt.Errorf("RESIZE_APPROVAL_SIZE must be one of %s: %s", strings.Join(Sizes, ", "), value)
+		}
+
+		options.ResizeApprovalSize = value
+	}
+
+	options.CreationApprovalGroup = os.Getenv("CREATION_APPROVAL_GROUP")
+	options.BudgetAdminGroup = os.Getenv("BUDGET_ADMIN_GROUP")
+
+	if value := os.Getenv("REJECTION_GRACE_PERIOD"); value != "" {
+		rejectionGracePeriod, err := time.ParseDuration(value)
+		if err != nil {
+			return Options{}, fmt.Errorf("parse REJECTION_GRACE_PERIOD: %w", err)
+		}
+
+		options.RejectionGracePeriod = rejectionGracePeriod
+	}
+
+	if value := os.Getenv("ENABLE_WEBHOOK"); value != "" {
+		webhookEnabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return Options{}, fmt.Errorf("parse ENABLE_WEBHOOK: %w", err)
+		}
+
+		options.WebhookEnabled = webhookEnabled
+	}
+
+	options.WebhookEnabled = options.WebhookEnabled || options.CreationApprovalGroup != "" || options.BudgetAdminGroup != ""
+	options.AuditLogPath = os.Getenv("AUDIT_LOG_PATH")
+	options.AuditWebhookURL = os.Getenv("AUDIT_WEBHOOK_URL")
+	options.NotificationConfigPath = os.Getenv("NOTIFICATION_CONFIG")
+
+	options.Email.Host = os.Getenv("SMTP_HOST")
+	options.Email.Username = os.Getenv("SMTP_USERNAME")
+	options.Email.Password = os.Getenv("SMTP_PASSWORD")
+	options.Email.From = os.Getenv("SMTP_FROM")
+	options.Email.TemplatePath = os.Getenv("EMAIL_TEMPLATE")
+
+	if value := os.Getenv("SMTP_PORT"); value != "" {
+		port, err := strconv.Atoi(value)
+		if err != nil || port < 1 || port > 65535 {
+			return Options{}, fmt.Errorf("SMTP_PORT must be a port number: %s", value)
+		}
+
+		options.Email.Port = port
+	}
+
+	if value := os.Getenv("EMAIL_DIGEST_INTERVAL"); value != "" {
+		digestInterval, err := time.ParseDuration(value)
+		if err != nil || digestInterval <= 0 {
+			return Options{}, fmt.Errorf("EMAIL_DIGEST_INTERVAL must be a positive duration: %s", value)
+		}
+
+		options.Email.DigestInterval = digestInterval
+	}
+
+	if value := os.Getenv("LEADER_ELECTION"); value != "" {
+		leaderElection, err := strconv.ParseBool(value)
+		if err != nil {
+			return Options{}, fmt.Errorf("parse LEADER_ELECTION: %w", err)
+		}
+
+		options.LeaderElection = leaderElection
+	}
+
+	if value := os.Getenv("LEASE_DURATION"); value != "" {
+		leaseDuration, err := time.ParseDuration(value)
+		if err != nil {
+			return Options{}, fmt.Errorf("parse LEASE_DURATION: %w", err)
+		}
+
+		options.LeaseDuration = leaseDuration
+	}
+
+	if value := os.Getenv("RENEW_DEADLINE"); value != "" {
+		renewDeadline, err := time.ParseDuration(value)
+		if err != nil {
+			return Options{}, fmt.Errorf("parse RENEW_DEADLINE: %w", err)
+		}
+
+		options.RenewDeadline = renewDeadline
+	}
+
+	if value := os.Getenv("AZURE_TENANT_ID"); value != "" {
+		options.IdentityProvider = identityProviderAzure
+		options.AzureTenantID = value
+	}
+
+	// PULL_SECRET_NAMES accepts a comma separated list and PULL_SECRET_NAME is
+	// still honored for a single secret
+	if os.Getenv("PULL_SECRET_NAMES") != "" || os.Getenv("PULL_SECRET_NAME") != "" {
+		options.PullSecretNames = nil
+		names := append(strings.Split(os.Getenv("PULL_SECRET_NAMES"), ","), os.Getenv("PULL_SECRET_NAME"))
+		for _, name := range names {
+			name = strings.TrimSpace(name)
+			if name != "" && !ContainsString(options.PullSecretNames, name) {
+				options.PullSecretNames = append(options.PullSecretNames, name)
+			}
+		}
+	}
+
+	if value := os.Getenv("PULL_SECRET_NAMESPACE"); value != "" {
+		options.PullSecretNamespace = value
+	}
+
+	if value := os.Getenv("SCALE_DOWN_TIMEOUT"); value != "" {
+		scaleDownTimeout, err := time.ParseDuration(value)
+		if err != nil {
+			return Options{}, fmt.Errorf("parse SCALE_DOWN_TIMEOUT: %w", err)
+		}
+
+		options.ScaleDownTimeout = scaleDownTimeout
+	}
+
+	if value := os.Getenv("NAMESPACE_STUCK_TIMEOUT"); value != "" {
+		namespaceStuckTimeout, err := time.ParseDuration(value)
+		if err != nil {
+			return Options{}, fmt.Errorf("parse NAMESPACE_STUCK_TIMEOUT: %w", err)
+		}
+
+		options.NamespaceStuckTimeout = namespaceStuckTimeout
+	}
+
+	options.APIAddress = os.Getenv("API_ADDRESS")
+	options.APITLSCertFile = os.Getenv("API_TLS_CERT")
+	options.APITLSKeyFile = os.Getenv("API_TLS_KEY")
+
+	if value := os.Getenv("API_TRUSTED_HEADERS"); value != "" {
+		trustedHeaders, err := strconv.ParseBool(value)
+		if err != nil {
+			return Options{}, fmt.Errorf("parse API_TRUSTED_HEADERS: %w", err)
+		}
+
+		options.APITrustedHeaders = trustedHeaders
+	}
+
+	options.APIClientCAFile = os.Getenv("API_CLIENT_CA")
+	for _, name := range strings.Split(os.Getenv("API_ALLOWED_NAMES"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			options.APIAllowedNames = append(options.APIAllowedNames, name)
+		}
+	}
+
+	if value := os.Getenv("API_INSECURE"); value != "" {
+		insecure, err := strconv.ParseBool(value)
+		if err != nil {
+			return Options{}, fmt.Errorf("parse API_INSECURE: %w", err)
+		}
+
+		options.APIInsecure = insecure
+	}
+
+	// The tenant can be set in the config file or the environment, so it is
+	// only checked once both are applied
+	if options.IdentityProvider == identityProviderAzure && options.AzureTenantID == "" {
+		return Options{}, fmt.Errorf("identityProvider.tenantID or AZURE_TENANT_ID is required for the %s identity provider", identityProviderAzure)
+	}
+
+	if (options.APITLSCertFile == "") != (options.APITLSKeyFile == "") {
+		return Options{}, fmt.Errorf("API_TLS_CERT and API_TLS_KEY must be set together")
+	}
+
+	// Callers send their bearer tokens to the API, so it is only served in
+	// plain text when that is asked for
+	if options.APIAddress != "" && options.APITLSCertFile == "" && !options.APIInsecure {
+		return Options{}, fmt.Errorf("API_TLS_CERT and API_TLS_KEY are required to serve the API, or API_INSECURE to serve it without TLS")
+	}
+
+	// Anyone could set the headers, so they are only trusted from a proxy
+	// that presents a client certificate
+	if options.APITrustedHeaders && (options.APIClientCAFile == "" || options.APITLSCertFile == "") {
+		return Options{}, fmt.Errorf("API_TRUSTED_HEADERS requires API_CLIENT_CA and API_TLS_CERT to verify the client certificate of the proxy")
+	}
+
+	if options.RenewDeadline >= options.LeaseDuration {
+		return Options{}, fmt.Errorf("RENEW_DEADLINE %s must be less than LEASE_DURATION %s", options.RenewDeadline, options.LeaseDuration)
+	}
+
+	if options.RateLimitQPS <= 0 || options.RateLimitBurst < 1 {
+		return Options{}, fmt.Errorf("RECONCILE_QPS %v and RECONCILE_BURST %d must be positive", options.RateLimitQPS, options.RateLimitBurst)
+	}
+
+	if options.BaseBackoff > options.MaxBackoff {
+		return Options{}, fmt.Errorf("RECONCILE_BASE_BACKOFF %s is greater than RECONCILE_MAX_BACKOFF %s", options.BaseBackoff, options.MaxBackoff)
+	}
+
+	return options, nil
+}
+
+// getRateLimiter returns a rate limiter that backs off each failing Sandbox exponentially
+func (o Options) getRateLimiter() workqueue.RateLimiter {
+	return workqueue.NewItemExponentialFailureRateLimiter(o.BaseBackoff, o.MaxBackoff)
+}
+
+// getReconcileLimiter returns the token bucket shared by all reconciles
+func (o Options) getReconcileLimiter() *rate.Limiter {
+	return rate.NewLimiter(rate.Limit(o.RateLimitQPS), o.RateLimitBurst)
+}
+
+// rateLimitedReconciler limits the rate of all reconciles with a token bucket,
+// and requeues failed reconciles using its own rate limiter, as the work
+// queue of the controller always retries errors with the default rate limiter
+type rateLimitedReconciler struct {
+	reconciler  reconcile.Reconciler
+	rateLimiter workqueue.RateLimiter
+
+	// limiter is shared by all reconciles, nil does not limit them
+	limiter *rate.Limiter
+}
+
+// Reconcile waits for a token, calls the wrapped reconciler and requeues the
+// request with backoff when it fails. Failures are counted here, since they
+// are not returned to the controller, and are logged by the wrapped reconciler.
+func (r *rateLimitedReconciler) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	if r.limiter != nil {
+		if err := r.limiter.Wait(context.Background()); err != nil {
+			log.Error(err, "Wait for reconcile rate limit", "sandbox", request.Name)
+		}
+	}
+
+	result, err := r.reconciler.Reconcile(request)
+	if err != nil {
+		reconcileErrors.Inc()
+		return reconcile.Result{RequeueAfter: r.rateLimiter.When(request)}, nil
+	}
+
+	r.rateLimiter.Forget(request)
+	return result, nil
+}
@@ -0,0 +1,145 @@
+// +build !integration
+
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+type failingReconciler struct {
+	failures int
+}
+
+func (f *failingReconciler) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	if f.failures > 0 {
+		f.failures--
+		return reconcile.Result{}, errors.New("reconcile failed")
+	}
+
+	return reconcile.Result{}, nil
+}
+
+func TestGetOptions_FromEnvironment(t *testing.T) {
+	os.Setenv("MAX_CONCURRENT_RECONCILES", "8")
+	os.Setenv("RESYNC_PERIOD", "30m")
+	defer os.Unsetenv("MAX_CONCURRENT_RECONCILES")
+	defer os.Unsetenv("RESYNC_PERIOD")
+
+	options, err := GetOptions()
+	if err != nil {
+		t.Fatalf("get options: %v", err)
+	}
+
+	if options.MaxConcurrentReconciles != 8 {
+		t.Errorf("expected MaxConcurrentReconciles to be 8 but was %d", options.MaxConcurrentReconciles)
+	}
+
+	if options.ResyncPeriod != 30*time.Minute {
+		t.Errorf("expected ResyncPeriod to be 30m but was %s", options.ResyncPeriod)
+	}
+}
+
+func TestGetOptions_RenewDeadlineAfterLease_ReturnsError(t *testing.T) {
+	os.Setenv("LEASE_DURATION", "10s")
+	os.Setenv("RENEW_DEADLINE", "15s")
+	defer os.Unsetenv("LEASE_DURATION")
+	defer os.Unsetenv("RENEW_DEADLINE")
+
+	if _, err := GetOptions(); err == nil {
+		t.Errorf("expected a renew deadline longer than the lease duration to be rejected")
+	}
+}
+
+func TestGetOptions_APIWithoutTLS_ReturnsError(t *testing.T) {
+	os.Setenv("API_ADDRESS", ":8443")
+	defer os.Unsetenv("API_ADDRESS")
+
+	if _, err := GetOptions(); err == nil {
+		t.Errorf("expected the API without TLS to be rejected")
+	}
+
+	os.Setenv("API_INSECURE", "true")
+	defer os.Unsetenv("API_INSECURE")
+
+	if _, err := GetOptions(); err != nil {
+		t.Errorf("expected the API without TLS to be allowed when insecure: %v", err)
+	}
+
+	os.Setenv("API_TRUSTED_HEADERS", "true")
+	defer os.Unsetenv("API_TRUSTED_HEADERS")
+
+	if _, err := GetOptions(); err == nil {
+		t.Errorf("expected trusted headers without a client CA to be rejected")
+	}
+}
+
+func TestRateLimitedReconciler_Failure_BacksOffExponentially(t *testing.T) {
+	options := DefaultOptions()
+	options.BaseBackoff = time.Second
+	options.MaxBackoff = time.Minute
+
+	r := rateLimitedReconciler{
+		reconciler:  &failingReconciler{failures: 2},
+		rateLimiter: options.getRateLimiter(),
+	}
+
+	request := reconcile.Request{
+		NamespacedName: types.NamespacedName{Name: "test"},
+	}
+
+	first, err := r.Reconcile(request)
+	if err != nil {
+		t.Fatalf("expected error to be handled but got: %v", err)
+	}
+
+	second, err := r.Reconcile(request)
+	if err != nil {
+		t.Fatalf("expected error to be handled but got: %v", err)
+	}
+
+	if first.RequeueAfter != time.Second || second.RequeueAfter != 2*time.Second {
+		t.Errorf("expected requeues after 1s and 2s but got %s and %s", first.RequeueAfter, second.RequeueAfter)
+	}
+
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+
+	if retries := r.rateLimiter.NumRequeues(request); retries != 0 {
+		t.Errorf("expected backoff to be reset after success but found %d requeues", retries)
+	}
+}
+
+func TestRateLimitedReconciler_Limiter_LimitsAllReconciles(t *testing.T) {
+	options := DefaultOptions()
+	options.RateLimitQPS = 10
+	options.RateLimitBurst = 1
+
+	r := rateLimitedReconciler{
+		reconciler:  &failingReconciler{},
+		rateLimiter: options.getRateLimiter(),
+		limiter:     options.getReconcileLimiter(),
+	}
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		request := reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: fmt.Sprintf("test-%d", i)},
+		}
+
+		if _, err := r.Reconcile(request); err != nil {
+			t.Fatalf("reconcile: %v", err)
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("expected successful reconciles to be limited to 10 per second but 3 took %s", elapsed)
+	}
+}
@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// sender sends queued audit records or notifications in the background. It is
+// drained when it is closed rather than when the manager stops, so that what
+// the last reconciles queued is still sent.
+type sender interface {
+	manager.Runnable
+
+	// Close stops queueing and returns a channel that is closed once what was
+	// queued has been sent
+	Close() <-chan struct{}
+}
+
+// sendQueue is a bounded queue whose items are sent one at a time by a single
+// worker, so that a slow endpoint neither holds up reconciles nor starts a
+// goroutine per item
+type sendQueue struct {
+	send func(item interface{})
+
+	mu     sync.Mutex
+	closed bool
+	items  chan interface{}
+	done   chan struct{}
+}
+
+// newSendQueue creates a queue of at most size items that are sent with send
+func newSendQueue(size int, send func(item interface{})) *sendQueue {
+	queue := sendQueue{
+		send:  send,
+		items: make(chan interface{}, size),
+		done:  make(chan struct{}),
+	}
+
+	return &queue
+}
+
+// add queues the item, and reports false when the queue is full or closed
+func (q *sendQueue) add(item interface{}) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return false
+	}
+
+	select {
+	case q.items <- item:
+		return true
+	default:
+		return false
+	}
+}
+
+// NeedLeaderElection reports that the queue is run by every replica, so that
+// a replica that loses leadership still sends what it queued
+func (q *sendQueue) NeedLeaderElection() bool {
+	return false
+}
+
+// Start sends the queued items until the queue is closed and drained
+func (q *sendQueue) Start(stop <-chan struct{}) error {
+	defer close(q.done)
+
+	for item := range q.items {
+		q.send(item)
+	}
+
+	return nil
+}
+
+// Close stops queueing items and returns a channel that is closed once the
+// queued items have been sent
+func (q *sendQueue) Close() <-chan struct{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !q.closed {
+		q.closed = true
+		close(q.items)
+	}
+
+	return q.done
+}
+
+// addSender runs the sender with the manager and closes it on Close
+func (r *ReconcileSandbox) addSender(mgr manager.Manager, s sender) error {
+	if err := mgr.Add(s); err != nil {
+		return err
+	}
+
+	r.senders = append(r.senders, s)
+	return nil
+}
+
+// Close stops queueing audit records and notifications, and waits until the
+// queued ones are sent or the timeout has passed. It reports whether they were
+// all sent. Close is called once the reconciles have finished.
+func (r *ReconcileSandbox) Close(timeout time.Duration) bool {
+	var done []<-chan struct{}
+	for _, s := range r.senders {
+		done = append(done, s.Close())
+	}
+
+	deadline := time.After(timeout)
+	for _, sent := range done {
+		select {
+		case <-sent:
+		case <-deadline:
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	operatorsv1alpha1 "github.com/plexsystems/sandbox-operator/apis/operators/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// quotaRejectionWindow is how recent a pod rejection must be to be reported on the Sandbox
+	quotaRejectionWindow = 5 * time.Minute
+
+	// quotaRejectionReason is the reason of the Events recorded when a controller fails to create a pod
+	quotaRejectionReason = "FailedCreate"
+)
+
+// updateQuotaStatus mirrors the usage of the ResourceQuota of the Sandbox into
+// its status and sets the QuotaPressure condition
+func (r *ReconcileSandbox) updateQuotaStatus(ctx context.Context, sandbox *operatorsv1alpha1.Sandbox) error {
+	resourceQuota := getResourceQuota(*sandbox)
+	if err := r.client.Get(ctx, types.NamespacedName{Name: resourceQuota.Name, Namespace: resourceQuota.Namespace}, &resourceQuota); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+
+		return fmt.Errorf("get ResourceQuota: %w", err)
+	}
+
+	quotaUsage := getQuotaUsage(resourceQuota.Status)
+	if len(quotaUsage) == 0 {
+		return nil
+	}
+
+	sandbox.Status.QuotaUsage = quotaUsage
+
+	threshold := r.getOptions().QuotaPressureThreshold
+
+	var pressured []string
+	for resourceName, percentage := range quotaUsage {
+		if percentage >= threshold {
+			pressured = append(pressured, fmt.Sprintf("%s is %d%% used", resourceName, percentage))
+		}
+	}
+
+	if len(pressured) == 0 {
+		setCondition(&sandbox.Status, operatorsv1alpha1.SandboxConditionQuotaPressure, corev1.ConditionFalse, "BelowThreshold", fmt.Sprintf("All resources are below %d%% of the quota", threshold))
+		return nil
+	}
+
+	sort.Strings(pressured)
+	previous, _ := getCondition(sandbox.Status, operatorsv1alpha1.SandboxConditionQuotaPressure)
+	setCondition(&sandbox.Status, operatorsv1alpha1.SandboxConditionQuotaPressure, corev1.ConditionTrue, "ThresholdExceeded", strings.Join(pressured, ", "))
+	if previous.Status != corev1.ConditionTrue {
+		r.notify(ctx, *sandbox, NotificationQuotaPressure, fmt.Sprintf("The sandbox is running out of quota: %s", strings.Join(pressured, ", ")))
+	}
+
+	return nil
+}
+
+// getQuotaUsage returns the percentage used of every resource in the quota
+func getQuotaUsage(resourceQuotaStatus corev1.ResourceQuotaStatus) map[string]int64 {
+	if resourceQuotaStatus.Used == nil {
+		return nil
+	}
+
+	quotaUsage := make(map[string]int64)
+	for resourceName, hard := range resourceQuotaStatus.Hard {
+		used := resourceQuotaStatus.Used[resourceName]
+		if hard.IsZero() {
+			if used.IsZero() {
+				quotaUsage[string(resourceName)] = 0
+			} else {
+				quotaUsage[string(resourceName)] = 100
+			}
+
+			continue
+		}
+
+		quotaUsage[string(resourceName)] = used.MilliValue() * 100 / hard.MilliValue()
+	}
+
+	return quotaUsage
+}
+
+// recordQuotaRejections records a Warning Event on the Sandbox when pods in its
+// namespace were recently rejected for exceeding the quota. The Events are read
+// from the index of the quota rejection informer, and a rejection is only
+// reported once.
+func (r *ReconcileSandbox) recordQuotaRejections(ctx context.Context, sandbox operatorsv1alpha1.Sandbox) error {
+	if r.quotaRejections == nil {
+		return nil
+	}
+
+	objects, err := r.quotaRejections.ByIndex(toolscache.NamespaceIndex, getNamespace(sandbox).Name)
+	if err != nil {
+		return fmt.Errorf("get events: %w", err)
+	}
+
+	var latest *corev1.Event
+	for _, object := range objects {
+		event, ok := object.(*corev1.Event)
+		if !ok || !isQuotaRejection(event) || time.Since(event.LastTimestamp.Time) > quotaRejectionWindow {
+			continue
+		}
+
+		if latest == nil || event.LastTimestamp.After(latest.LastTimestamp.Time) {
+			latest = event
+		}
+	}
+
+	if latest == nil {
+		r.reportedRejections.Delete(sandbox.Name)
+		return nil
+	}
+
+	rejection := fmt.Sprintf("%s/%s@%s", latest.Namespace, latest.Name, latest.LastTimestamp.UTC().Format(time.RFC3339))
+	if previous, ok := r.reportedRejections.Load(sandbox.Name); !ok || previous != rejection {
+		r.reportedRejections.Store(sandbox.Name, rejection)
+		r.recorder.Eventf(&sandbox, corev1.EventTypeWarning, "QuotaExceeded", "Pods for %s %s are being rejected: %s", latest.InvolvedObject.Kind, latest.InvolvedObject.Name, latest.Message)
+	}
+
+	return nil
+}
+
+// isQuotaRejection reports whether the event is a pod that failed to be created
+// because it would exceed the quota of its namespace
+func isQuotaRejection(object metav1.Object) bool {
+	event, ok := object.(*corev1.Event)
+	if !ok {
+		return false
+	}
+
+	return event.Reason == quotaRejectionReason && strings.Contains(event.Message, "exceeded quota")
+}
@@ -0,0 +1,128 @@
+// +build !integration
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/plexsystems/sandbox-operator/apis"
+	operatorsv1alpha1 "github.com/plexsystems/sandbox-operator/apis/operators/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestSandboxController_QuotaAboveThreshold_SetsQuotaPressure(t *testing.T) {
+	ctx := context.TODO()
+
+	s := scheme.Scheme
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatalf("add scheme: %v", err)
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	fakeClient := fake.NewFakeClientWithScheme(s)
+	r := NewReconcileSandbox(fakeClient, fakeClient, s, DefaultSubjects{}, recorder)
+
+	sandbox := operatorsv1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+	}
+
+	if err := r.client.Create(ctx, &sandbox); err != nil {
+		t.Fatalf("create sandbox: %v", err)
+	}
+
+	request := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name: sandbox.Name,
+		},
+	}
+
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("reconcile sandbox: %v", err)
+	}
+
+	resourceQuota := getResourceQuota(sandbox)
+	if err := r.client.Get(ctx, types.NamespacedName{Name: resourceQuota.Name, Namespace: resourceQuota.Namespace}, &resourceQuota); err != nil {
+		t.Fatalf("get resource quota: %v", err)
+	}
+
+	resourceQuota.Status = corev1.ResourceQuotaStatus{
+		Hard: resourceQuota.Spec.Hard,
+		Used: corev1.ResourceList{
+			corev1.ResourceLimitsCPU:    resource.MustParse("0.475"),
+			corev1.ResourceLimitsMemory: resource.MustParse("250Mi"),
+		},
+	}
+
+	if err := r.client.Update(ctx, &resourceQuota); err != nil {
+		t.Fatalf("update resource quota: %v", err)
+	}
+
+	rejection := corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-rejection",
+			Namespace: resourceQuota.Namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			Kind: "ReplicaSet",
+			Name: "test",
+		},
+		Reason:        "FailedCreate",
+		Message:       `Error creating: pods "test" is forbidden: exceeded quota: sandbox-test-resourcequota`,
+		LastTimestamp: metav1.NewTime(time.Now()),
+	}
+
+	r.quotaRejections = toolscache.NewIndexer(toolscache.MetaNamespaceKeyFunc, toolscache.Indexers{
+		toolscache.NamespaceIndex: toolscache.MetaNamespaceIndexFunc,
+	})
+
+	if err := r.quotaRejections.Add(&rejection); err != nil {
+		t.Fatalf("add event: %v", err)
+	}
+
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("reconcile sandbox: %v", err)
+	}
+
+	var foundSandbox operatorsv1alpha1.Sandbox
+	if err := r.client.Get(ctx, request.NamespacedName, &foundSandbox); err != nil {
+		t.Fatalf("get sandbox: %v", err)
+	}
+
+	if foundSandbox.Status.QuotaUsage["limits.cpu"] != 95 || foundSandbox.Status.QuotaUsage["limits.memory"] != 50 {
+		t.Errorf("expected limits.cpu to be 95%% and limits.memory to be 50%% used but found: %v", foundSandbox.Status.QuotaUsage)
+	}
+
+	if len(foundSandbox.Status.Conditions) != 1 {
+		t.Fatalf("expected a QuotaPressure condition but found: %v", foundSandbox.Status.Conditions)
+	}
+
+	condition := foundSandbox.Status.Conditions[0]
+	if condition.Type != operatorsv1alpha1.SandboxConditionQuotaPressure || condition.Status != corev1.ConditionTrue {
+		t.Errorf("expected QuotaPressure to be true but found: %v", condition)
+	}
+
+	if !hasEvent(recorder, "QuotaExceeded") {
+		t.Errorf("expected a QuotaExceeded event but none was recorded")
+	}
+
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("reconcile sandbox: %v", err)
+	}
+
+	if hasEvent(recorder, "QuotaExceeded") {
+		t.Errorf("expected the same rejection to only be reported once")
+	}
+}
@@ -0,0 +1,237 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	operatorsv1alpha1 "github.com/plexsystems/sandbox-operator/apis/operators/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// maxResizeHistory is how many resizes are kept in the status of a Sandbox
+const maxResizeHistory = 10
+
+// Sizes lists the sizes of a Sandbox from smallest to largest
+var Sizes = []string{"small", "large"}
+
+// getSizeRank returns the position of the size from smallest to largest
+func getSizeRank(size string) int {
+	for rank, s := range Sizes {
+		if s == size {
+			return rank
+		}
+	}
+
+	return 0
+}
+
+// requiresApproval reports whether resizing a Sandbox up to the given size needs to be approved
+func (o Options) requiresApproval(size string) bool {
+	return o.ResizeApprovalSize != "" && getSizeRank(size) >= getSizeRank(o.ResizeApprovalSize)
+}
+
+// GetAppliedSize returns the size of the ResourceQuota the sandbox is given,
+// which is the size in its spec until a resize is waiting for approval
+func GetAppliedSize(sandbox operatorsv1alpha1.Sandbox) string {
+	if sandbox.Status.Size != "" {
+		return sandbox.Status.Size
+	}
+
+	return getSize(sandbox)
+}
+
+// reconcileSize applies the size in the spec of the Sandbox to its status.
+// Decreases and increases below the approval size are applied right away,
+// other increases keep the current size until a SandboxResizeRequest for the
+// new size has been approved.
+func (r *ReconcileSandbox) reconcileSize(ctx context.Context, sandbox *operatorsv1alpha1.Sandbox, now time.Time) error {
+	desiredSize := getSize(*sandbox)
+
+	// Sandboxes provisioned before their size was tracked keep their size,
+	// new Sandboxes start out at the smallest size until they are approved
+	if sandbox.Status.Size == "" {
+		sandbox.Status.Size = desiredSize
+		if !isProvisioned(*sandbox) && r.getOptions().requiresApproval(desiredSize) {
+			sandbox.Status.Size = Sizes[0]
+		}
+	}
+
+	appliedSize := sandbox.Status.Size
+
+	var requests operatorsv1alpha1.SandboxResizeRequestList
+	if err := r.client.List(ctx, &requests); err != nil {
+		return fmt.Errorf("list SandboxResizeRequests: %w", err)
+	}
+
+	for i := range requests.Items {
+		request := &requests.Items[i]
+		if request.Spec.Sandbox != sandbox.Name || (request.Spec.ToSize == desiredSize && appliedSize != desiredSize) {
+			continue
+		}
+
+		if err := r.client.Delete(ctx, request); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("delete stale SandboxResizeRequest %s: %w", request.Name, err)
+		}
+	}
+
+	if appliedSize == desiredSize {
+		if _, ok := getCondition(sandbox.Status, operatorsv1alpha1.SandboxConditionResizePending); ok {
+			setCondition(&sandbox.Status, operatorsv1alpha1.SandboxConditionResizePending, corev1.ConditionFalse, "Resized", fmt.Sprintf("The sandbox is %s", appliedSize))
+		}
+
+		return nil
+	}
+
+	if getSizeRank(desiredSize) < getSizeRank(appliedSize) {
+		return r.reconcileDownsize(ctx, sandbox, desiredSize, now)
+	}
+
+	if !r.getOptions().requiresApproval(desiredSize) {
+		applyResize(sandbox, desiredSize, "Applied", now)
+		return nil
+	}
+
+	request := getResizeRequest(*sandbox, appliedSize, desiredSize)
+	if err := r.client.Get(ctx, types.NamespacedName{Name: request.Name}, &request); err != nil {
+		if !errors.IsNotFound(err) {
+			return fmt.Errorf("get SandboxResizeRequest: %w", err)
+		}
+
+		if err := controllerutil.SetControllerReference(sandbox, &request, r.scheme); err != nil {
+			return fmt.Errorf("set owner of SandboxResizeRequest: %w", err)
+		}
+
+		if err := r.client.Create(ctx, &request); err != nil {
+			return fmt.Errorf("create SandboxResizeRequest: %w", err)
+		}
+
+		logOperation(ctx, "SandboxResizeRequest", request.Name, controllerutil.OperationResultCreated)
+		r.recorder.Eventf(sandbox, corev1.EventTypeNormal, "ResizePending", "Resize from %s to %s is waiting for approval in SandboxResizeRequest %s", appliedSize, desiredSize, request.Name)
+	}
+
+	switch request.Spec.Decision {
+	case operatorsv1alpha1.SandboxResizeApproved:
+		if err := r.client.Delete(ctx, &request); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("delete approved SandboxResizeRequest: %w", err)
+		}
+
+		applyResize(sandbox, desiredSize, string(operatorsv1alpha1.SandboxResizeApproved), now)
+		r.recorder.Eventf(sandbox, corev1.EventTypeNormal, "ResizeApproved", "Resize from %s to %s was approved", appliedSize, desiredSize)
+
+	case operatorsv1alpha1.SandboxResizeRejected:
+		message := fmt.Sprintf("Resize from %s to %s was rejected", appliedSize, desiredSize)
+		if request.Spec.Reason != "" {
+			message += ": " + request.Spec.Reason
+		}
+
+		// The request is kept until the spec changes so the rejection is only recorded once
+		previous, _ := getCondition(sandbox.Status, operatorsv1alpha1.SandboxConditionResizePending)
+		if previous.Reason != "Rejected" {
+			addResizeHistory(sandbox, appliedSize, desiredSize, string(operatorsv1alpha1.SandboxResizeRejected), now)
+			r.recorder.Event(sandbox, corev1.EventTypeWarning, "ResizeRejected", message)
+		}
+
+		setCondition(&sandbox.Status, operatorsv1alpha1.SandboxConditionResizePending, corev1.ConditionFalse, "Rejected", message)
+
+	default:
+		setCondition(&sandbox.Status, operatorsv1alpha1.SandboxConditionResizePending, corev1.ConditionTrue, "AwaitingApproval", fmt.Sprintf("Resize from %s to %s is waiting for approval in SandboxResizeRequest %s", appliedSize, desiredSize, request.Name))
+	}
+
+	return nil
+}
+
+// reconcileDownsize applies a smaller size once the workloads in the sandbox
+// fit in its ResourceQuota. Until then the current size is kept, as pods that
+// exceed a smaller quota could not be restarted. Changes to the usage of the
+// ResourceQuota trigger another reconcile.
+func (r *ReconcileSandbox) reconcileDownsize(ctx context.Context, sandbox *operatorsv1alpha1.Sandbox, size string, now time.Time) error {
+	resourceQuota := getResourceQuota(*sandbox)
+	if err := r.client.Get(ctx, types.NamespacedName{Name: resourceQuota.Name, Namespace: resourceQuota.Namespace}, &resourceQuota); err != nil {
+		if errors.IsNotFound(err) {
+			applyResize(sandbox, size, "Applied", now)
+			return nil
+		}
+
+		return fmt.Errorf("get ResourceQuota: %w", err)
+	}
+
+	exceeded := getExceededResources(resourceQuota.Status.Used, getResourceQuotaSpec(size).Hard)
+	if len(exceeded) == 0 {
+		applyResize(sandbox, size, "Applied", now)
+		return nil
+	}
+
+	message := fmt.Sprintf("Resize from %s to %s is waiting for usage to drop: %s", sandbox.Status.Size, size, strings.Join(exceeded, ", "))
+	previous, _ := getCondition(sandbox.Status, operatorsv1alpha1.SandboxConditionResizePending)
+	if previous.Reason != "UsageTooHigh" {
+		r.recorder.Event(sandbox, corev1.EventTypeWarning, "ResizeBlocked", message)
+	}
+
+	setCondition(&sandbox.Status, operatorsv1alpha1.SandboxConditionResizePending, corev1.ConditionTrue, "UsageTooHigh", message)
+	return nil
+}
+
+// getExceededResources describes the resources whose usage is above the given hard limits
+func getExceededResources(used corev1.ResourceList, hard corev1.ResourceList) []string {
+	var exceeded []string
+	for name, limit := range hard {
+		usage, ok := used[name]
+		if !ok || usage.Cmp(limit) <= 0 {
+			continue
+		}
+
+		exceeded = append(exceeded, fmt.Sprintf("%s uses %s of %s", name, usage.String(), limit.String()))
+	}
+
+	sort.Strings(exceeded)
+	return exceeded
+}
+
+// applyResize changes the applied size of the Sandbox and records the resize
+func applyResize(sandbox *operatorsv1alpha1.Sandbox, size string, decision string, now time.Time) {
+	addResizeHistory(sandbox, sandbox.Status.Size, size, decision, now)
+	sandbox.Status.Size = size
+
+	if _, ok := getCondition(sandbox.Status, operatorsv1alpha1.SandboxConditionResizePending); ok {
+		setCondition(&sandbox.Status, operatorsv1alpha1.SandboxConditionResizePending, corev1.ConditionFalse, "Resized", fmt.Sprintf("The sandbox is %s", size))
+	}
+}
+
+// addResizeHistory records a resize decision, keeping only the most recent resizes
+func addResizeHistory(sandbox *operatorsv1alpha1.Sandbox, fromSize string, toSize string, decision string, now time.Time) {
+	resize := operatorsv1alpha1.SandboxResize{
+		FromSize: fromSize,
+		ToSize:   toSize,
+		Decision: decision,
+		Time:     metav1.NewTime(now),
+	}
+
+	sandbox.Status.ResizeHistory = append(sandbox.Status.ResizeHistory, resize)
+	if len(sandbox.Status.ResizeHistory) > maxResizeHistory {
+		sandbox.Status.ResizeHistory = sandbox.Status.ResizeHistory[len(sandbox.Status.ResizeHistory)-maxResizeHistory:]
+	}
+}
+
+func getResizeRequest(sandbox operatorsv1alpha1.Sandbox, fromSize string, toSize string) operatorsv1alpha1.SandboxResizeRequest {
+	request := operatorsv1alpha1.SandboxResizeRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   sandbox.Name + "-" + toSize,
+			Labels: getCommonLabels(),
+		},
+		Spec: operatorsv1alpha1.SandboxResizeRequestSpec{
+			Sandbox:  sandbox.Name,
+			FromSize: fromSize,
+			ToSize:   toSize,
+		},
+	}
+
+	return request
+}
@@ -0,0 +1,252 @@
+// +build !integration
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/plexsystems/sandbox-operator/apis"
+	operatorsv1alpha1 "github.com/plexsystems/sandbox-operator/apis/operators/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestSandboxController_ResizeToApprovalSize_WaitsForApproval(t *testing.T) {
+	ctx := context.TODO()
+
+	s := scheme.Scheme
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatalf("add scheme: %v", err)
+	}
+
+	recorder := record.NewFakeRecorder(20)
+	fakeClient := fake.NewFakeClientWithScheme(s)
+	r := NewReconcileSandbox(fakeClient, fakeClient, s, DefaultSubjects{}, recorder)
+	r.options.ResizeApprovalSize = "large"
+
+	sandbox := operatorsv1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+		Spec: operatorsv1alpha1.SandboxSpec{
+			Size: "small",
+		},
+	}
+
+	if err := r.client.Create(ctx, &sandbox); err != nil {
+		t.Fatalf("create sandbox: %v", err)
+	}
+
+	request := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name: sandbox.Name,
+		},
+	}
+
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("reconcile sandbox: %v", err)
+	}
+
+	var foundSandbox operatorsv1alpha1.Sandbox
+	if err := r.client.Get(ctx, request.NamespacedName, &foundSandbox); err != nil {
+		t.Fatalf("get sandbox: %v", err)
+	}
+
+	foundSandbox.Spec.Size = "large"
+	if err := r.client.Update(ctx, &foundSandbox); err != nil {
+		t.Fatalf("update sandbox: %v", err)
+	}
+
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("reconcile sandbox: %v", err)
+	}
+
+	resourceQuota := getResourceQuota(sandbox)
+	if err := r.client.Get(ctx, types.NamespacedName{Name: resourceQuota.Name, Namespace: resourceQuota.Namespace}, &resourceQuota); err != nil {
+		t.Fatalf("get resource quota: %v", err)
+	}
+
+	expectedCPU := getSmallResourceQuotaSpec().Hard[corev1.ResourceRequestsCPU]
+	if cpu := resourceQuota.Spec.Hard[corev1.ResourceRequestsCPU]; cpu.Cmp(expectedCPU) != 0 {
+		t.Errorf("expected the small ResourceQuota to be kept until approval but found cpu %s", cpu.String())
+	}
+
+	if err := r.client.Get(ctx, request.NamespacedName, &foundSandbox); err != nil {
+		t.Fatalf("get sandbox: %v", err)
+	}
+
+	condition, ok := getCondition(foundSandbox.Status, operatorsv1alpha1.SandboxConditionResizePending)
+	if !ok || condition.Status != corev1.ConditionTrue {
+		t.Errorf("expected ResizePending to be True but found: %v", foundSandbox.Status.Conditions)
+	}
+
+	if !hasEvent(recorder, "ResizePending") {
+		t.Errorf("expected a ResizePending event but none was recorded")
+	}
+
+	var resizeRequest operatorsv1alpha1.SandboxResizeRequest
+	if err := r.client.Get(ctx, types.NamespacedName{Name: "test-large"}, &resizeRequest); err != nil {
+		t.Fatalf("get resize request: %v", err)
+	}
+
+	resizeRequest.Spec.Decision = operatorsv1alpha1.SandboxResizeApproved
+	if err := r.client.Update(ctx, &resizeRequest); err != nil {
+		t.Fatalf("approve resize request: %v", err)
+	}
+
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("reconcile sandbox: %v", err)
+	}
+
+	if err := r.client.Get(ctx, types.NamespacedName{Name: resourceQuota.Name, Namespace: resourceQuota.Namespace}, &resourceQuota); err != nil {
+		t.Fatalf("get resource quota: %v", err)
+	}
+
+	expectedCPU = getLargeResourceQuotaSpec().Hard[corev1.ResourceRequestsCPU]
+	if cpu := resourceQuota.Spec.Hard[corev1.ResourceRequestsCPU]; cpu.Cmp(expectedCPU) != 0 {
+		t.Errorf("expected the large ResourceQuota after approval but found cpu %s", cpu.String())
+	}
+
+	if err := r.client.Get(ctx, types.NamespacedName{Name: "test-large"}, &resizeRequest); !errors.IsNotFound(err) {
+		t.Errorf("expected the approved resize request to be deleted but found: %v", err)
+	}
+
+	if err := r.client.Get(ctx, request.NamespacedName, &foundSandbox); err != nil {
+		t.Fatalf("get sandbox: %v", err)
+	}
+
+	history := foundSandbox.Status.ResizeHistory
+	if len(history) != 1 || history[0].ToSize != "large" || history[0].Decision != "Approved" {
+		t.Errorf("expected an approved resize in the history but found: %v", history)
+	}
+
+	foundSandbox.Spec.Size = "small"
+	if err := r.client.Update(ctx, &foundSandbox); err != nil {
+		t.Fatalf("update sandbox: %v", err)
+	}
+
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("reconcile sandbox: %v", err)
+	}
+
+	if err := r.client.Get(ctx, request.NamespacedName, &foundSandbox); err != nil {
+		t.Fatalf("get sandbox: %v", err)
+	}
+
+	if foundSandbox.Status.Size != "small" {
+		t.Errorf("expected the decrease to be applied immediately but size was %s", foundSandbox.Status.Size)
+	}
+}
+
+func TestSandboxController_DownsizeAboveUsage_WaitsForUsageToDrop(t *testing.T) {
+	ctx := context.TODO()
+
+	s := scheme.Scheme
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatalf("add scheme: %v", err)
+	}
+
+	recorder := record.NewFakeRecorder(20)
+	fakeClient := fake.NewFakeClientWithScheme(s)
+	r := NewReconcileSandbox(fakeClient, fakeClient, s, DefaultSubjects{}, recorder)
+
+	sandbox := operatorsv1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+		Spec: operatorsv1alpha1.SandboxSpec{
+			Size: "large",
+		},
+	}
+
+	if err := r.client.Create(ctx, &sandbox); err != nil {
+		t.Fatalf("create sandbox: %v", err)
+	}
+
+	request := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name: sandbox.Name,
+		},
+	}
+
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("reconcile sandbox: %v", err)
+	}
+
+	resourceQuota := getResourceQuota(sandbox)
+	if err := r.client.Get(ctx, types.NamespacedName{Name: resourceQuota.Name, Namespace: resourceQuota.Namespace}, &resourceQuota); err != nil {
+		t.Fatalf("get resource quota: %v", err)
+	}
+
+	resourceQuota.Status.Used = corev1.ResourceList{
+		corev1.ResourceRequestsCPU: resource.MustParse("500m"),
+	}
+
+	if err := r.client.Status().Update(ctx, &resourceQuota); err != nil {
+		t.Fatalf("update resource quota status: %v", err)
+	}
+
+	var foundSandbox operatorsv1alpha1.Sandbox
+	if err := r.client.Get(ctx, request.NamespacedName, &foundSandbox); err != nil {
+		t.Fatalf("get sandbox: %v", err)
+	}
+
+	foundSandbox.Spec.Size = "small"
+	if err := r.client.Update(ctx, &foundSandbox); err != nil {
+		t.Fatalf("update sandbox: %v", err)
+	}
+
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("reconcile sandbox: %v", err)
+	}
+
+	if err := r.client.Get(ctx, request.NamespacedName, &foundSandbox); err != nil {
+		t.Fatalf("get sandbox: %v", err)
+	}
+
+	if foundSandbox.Status.Size != "large" {
+		t.Errorf("expected the large size to be kept while usage is too high but size was %s", foundSandbox.Status.Size)
+	}
+
+	condition, ok := getCondition(foundSandbox.Status, operatorsv1alpha1.SandboxConditionResizePending)
+	if !ok || condition.Reason != "UsageTooHigh" {
+		t.Errorf("expected ResizePending to be UsageTooHigh but found: %v", foundSandbox.Status.Conditions)
+	}
+
+	if !hasEvent(recorder, "ResizeBlocked") {
+		t.Errorf("expected a ResizeBlocked event but none was recorded")
+	}
+
+	if err := r.client.Get(ctx, types.NamespacedName{Name: resourceQuota.Name, Namespace: resourceQuota.Namespace}, &resourceQuota); err != nil {
+		t.Fatalf("get resource quota: %v", err)
+	}
+
+	resourceQuota.Status.Used = corev1.ResourceList{
+		corev1.ResourceRequestsCPU: resource.MustParse("100m"),
+	}
+
+	if err := r.client.Status().Update(ctx, &resourceQuota); err != nil {
+		t.Fatalf("update resource quota status: %v", err)
+	}
+
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("reconcile sandbox: %v", err)
+	}
+
+	if err := r.client.Get(ctx, request.NamespacedName, &foundSandbox); err != nil {
+		t.Fatalf("get sandbox: %v", err)
+	}
+
+	if foundSandbox.Status.Size != "small" {
+		t.Errorf("expected the small size once usage dropped but size was %s", foundSandbox.Status.Size)
+	}
+}
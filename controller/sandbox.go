@@ -2,34 +2,48 @@ package controller
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"log"
-	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	operatorsv1alpha1 "github.com/plexsystems/sandbox-operator/apis/operators/v1alpha1"
 
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 var _ reconcile.Reconciler = &ReconcileSandbox{}
 
+// skipPullSecretsAnnotation opts a ServiceAccount out of having pull secrets attached
+const skipPullSecretsAnnotation = "operators.plex.dev/skip-pull-secrets"
+
 // SubjectsClient defines a client that gets subjects
 type SubjectsClient interface {
 	Subjects(ctx context.Context, users []string) ([]rbacv1.Subject, error)
@@ -37,30 +51,66 @@ type SubjectsClient interface {
 
 // ReconcileSandbox reconciles a Sandbox object
 type ReconcileSandbox struct {
+	// inFlight is the number of reconciles in progress
+	inFlight int32
+
+	// reconcileStarts holds the start time of each reconcile in progress by
+	// its reconcile ID, so that a stuck reconcile fails the liveness check
+	reconcileStarts sync.Map
+
 	client         client.Client
+	apiReader      client.Reader
 	scheme         *runtime.Scheme
 	subjectsClient SubjectsClient
+	recorder       record.EventRecorder
+	auditSink      AuditSink
+	notifier       *Notifier
+	emailNotifier  *EmailNotifier
+
+	// senders send audit records and notifications in the background, and are
+	// drained on Close
+	senders []sender
+
+	// mu guards the options and subjects client, which are replaced when the
+	// config is reloaded
+	mu      sync.RWMutex
+	options Options
+
+	// provisionFailures holds the last provisioning error of each Sandbox so
+	// that owners are only notified when the error changes
+	provisionFailures sync.Map
+
+	// reportedRejections holds the last quota rejection reported on each
+	// Sandbox so that it is not reported again on every reconcile
+	reportedRejections sync.Map
+
+	// pullSecretCache caches the Secrets of pullSecretCacheNamespace only, so
+	// that the source pull secrets are not read from a cluster-wide cache
+	pullSecretCache          client.Reader
+	pullSecretCacheNamespace string
+
+	// sourceCaches caches the namespaces of the SandboxSharedResource sources
+	sourceCaches *sourceCaches
+
+	// quotaRejections indexes the FailedCreate Events by namespace
+	quotaRejections toolscache.Indexer
 }
 
-// NewReconcileSandbox creates a new reconciler for Sandbox resources
-func NewReconcileSandbox(scheme *runtime.Scheme) (*ReconcileSandbox, error) {
-	client, err := NewClient(scheme)
-	if err != nil {
-		return nil, fmt.Errorf("new client: %w", err)
-	}
-
-	subjects, err := newSubjectsClient()
-	if err != nil {
-		return nil, fmt.Errorf("new subjects: %w", err)
-	}
-
+// NewReconcileSandbox creates a new reconciler for Sandbox resources.
+// The client is expected to read from the manager's cache, while the apiReader
+// reads directly from the API server where a stale or cluster-wide cached read
+// is not wanted.
+func NewReconcileSandbox(client client.Client, apiReader client.Reader, scheme *runtime.Scheme, subjectsClient SubjectsClient, recorder record.EventRecorder) *ReconcileSandbox {
 	reconcileSandbox := ReconcileSandbox{
 		client:         client,
+		apiReader:      apiReader,
 		scheme:         scheme,
-		subjectsClient: subjects,
+		subjectsClient: subjectsClient,
+		recorder:       recorder,
+		options:        DefaultOptions(),
 	}
 
-	return &reconcileSandbox, nil
+	return &reconcileSandbox
 }
 
 // NewClient creates a new kubernetes client
@@ -78,158 +128,759 @@ func NewClient(scheme *runtime.Scheme) (client.Client, error) {
 	return client, nil
 }
 
-// Add creates a new Sandbox controller and adds it to the controller manager
-func Add(mgr manager.Manager) error {
-	reconcileSandbox, err := NewReconcileSandbox(mgr.GetScheme())
+// Add creates a new Sandbox controller and adds it to the controller manager.
+// The reconciler is returned so that its health can be checked and shutdown
+// can wait for its reconciles to finish.
+func Add(mgr manager.Manager, options Options) (*ReconcileSandbox, error) {
+	subjects, err := newSubjectsClient(options)
 	if err != nil {
-		return fmt.Errorf("new reconciler: %w", err)
+		return nil, fmt.Errorf("new subjects: %w", err)
+	}
+
+	reconcileSandbox := NewReconcileSandbox(mgr.GetClient(), mgr.GetAPIReader(), mgr.GetScheme(), subjects, mgr.GetEventRecorderFor("sandbox-controller"))
+	if err := AddReconciler(mgr, reconcileSandbox, options); err != nil {
+		return nil, err
+	}
+
+	return reconcileSandbox, nil
+}
+
+// AddReconciler creates a new Sandbox controller using the given reconciler
+// and adds it to the controller manager
+func AddReconciler(mgr manager.Manager, reconcileSandbox *ReconcileSandbox, options Options) error {
+	reconcileSandbox.options = options
+
+	auditSink, auditSenders, err := newAuditSink(options)
+	if err != nil {
+		return fmt.Errorf("new audit sink: %w", err)
+	}
+
+	for _, auditSender := range auditSenders {
+		if err := reconcileSandbox.addSender(mgr, auditSender); err != nil {
+			return fmt.Errorf("add audit sender: %w", err)
+		}
+	}
+
+	reconcileSandbox.auditSink = auditSink
+
+	if options.NotificationConfigPath != "" {
+		notificationConfig, err := LoadNotificationConfig(options.NotificationConfigPath)
+		if err != nil {
+			return fmt.Errorf("load notification config: %w", err)
+		}
+
+		notifier, err := NewNotifier(notificationConfig)
+		if err != nil {
+			return fmt.Errorf("new notifier: %w", err)
+		}
+
+		if err := reconcileSandbox.addSender(mgr, notifier); err != nil {
+			return fmt.Errorf("add notifier: %w", err)
+		}
+
+		reconcileSandbox.notifier = notifier
 	}
 
-	c, err := controller.New("sandbox-controller", mgr, controller.Options{Reconciler: reconcileSandbox})
+	if options.Email.Host != "" {
+		emailNotifier, err := NewEmailNotifier(options.Email)
+		if err != nil {
+			return fmt.Errorf("new email notifier: %w", err)
+		}
+
+		if err := reconcileSandbox.addSender(mgr, emailNotifier); err != nil {
+			return fmt.Errorf("add email notifier: %w", err)
+		}
+
+		reconcileSandbox.emailNotifier = emailNotifier
+	}
+
+	if err := mgr.Add(manager.RunnableFunc(recordLeadership)); err != nil {
+		return fmt.Errorf("add leadership metric: %w", err)
+	}
+
+	rateLimitedReconciler := rateLimitedReconciler{
+		reconciler:  reconcileSandbox,
+		rateLimiter: options.getRateLimiter(),
+		limiter:     options.getReconcileLimiter(),
+	}
+
+	controllerOptions := controller.Options{
+		Reconciler:              &rateLimitedReconciler,
+		MaxConcurrentReconciles: options.MaxConcurrentReconciles,
+	}
+
+	c, err := controller.New("sandbox-controller", mgr, controllerOptions)
 	if err != nil {
 		return fmt.Errorf("new controller: %w", err)
 	}
 
+	if err := metrics.Registry.Register(sandboxCollector{client: reconcileSandbox.client}); err != nil {
+		return fmt.Errorf("register sandbox metrics: %w", err)
+	}
+
 	if err := c.Watch(&source.Kind{Type: &operatorsv1alpha1.Sandbox{}}, &handler.EnqueueRequestForObject{}); err != nil {
 		return fmt.Errorf("watch Sandbox: %w", err)
 	}
 
+	enqueueOwner := &handler.EnqueueRequestForOwner{
+		OwnerType:    &operatorsv1alpha1.Sandbox{},
+		IsController: true,
+	}
+
+	ownedTypes := []runtime.Object{
+		&corev1.Namespace{},
+		&corev1.ResourceQuota{},
+		&rbacv1.Role{},
+		&rbacv1.RoleBinding{},
+		&rbacv1.ClusterRole{},
+		&rbacv1.ClusterRoleBinding{},
+		&corev1.Secret{},
+		&operatorsv1alpha1.SandboxResizeRequest{},
+	}
+
+	for _, ownedType := range ownedTypes {
+		if err := c.Watch(&source.Kind{Type: ownedType}, enqueueOwner); err != nil {
+			return fmt.Errorf("watch %T: %w", ownedType, err)
+		}
+	}
+
+	ctx := context.Background()
+	enqueueAllSandboxes := &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(handler.MapObject) []reconcile.Request {
+			return reconcileSandbox.getSandboxRequests(ctx)
+		}),
+	}
+
+	// Status updates of SandboxSharedResources do not change their generation,
+	// so setting their conditions does not reconcile every Sandbox again
+	if err := c.Watch(&source.Kind{Type: &operatorsv1alpha1.SandboxSharedResource{}}, enqueueAllSandboxes, predicate.GenerationChangedPredicate{}); err != nil {
+		return fmt.Errorf("watch SandboxSharedResource: %w", err)
+	}
+
+	if options.ConfigPath != "" {
+		reloaded := make(chan event.GenericEvent, 1)
+		if err := c.Watch(&source.Channel{Source: reloaded}, enqueueAllSandboxes); err != nil {
+			return fmt.Errorf("watch config reloads: %w", err)
+		}
+
+		reloader := configReloader{
+			path:       options.ConfigPath,
+			interval:   10 * time.Second,
+			reconciler: reconcileSandbox,
+			reloaded:   reloaded,
+		}
+
+		if err := mgr.Add(reloader); err != nil {
+			return fmt.Errorf("add config reloader: %w", err)
+		}
+	}
+
+	// The source pull secrets are watched through a cache of the pull secret
+	// namespace, rather than through the cache of the manager
+	pullSecretCache, err := cache.New(mgr.GetConfig(), cache.Options{
+		Scheme:    mgr.GetScheme(),
+		Mapper:    mgr.GetRESTMapper(),
+		Namespace: options.PullSecretNamespace,
+	})
+	if err != nil {
+		return fmt.Errorf("new pull secret cache: %w", err)
+	}
+
+	if err := mgr.Add(pullSecretCache); err != nil {
+		return fmt.Errorf("add pull secret cache: %w", err)
+	}
+
+	reconcileSandbox.pullSecretCache = pullSecretCache
+	reconcileSandbox.pullSecretCacheNamespace = options.PullSecretNamespace
+
+	pullSecretSource := source.Kind{Type: &corev1.Secret{}}
+	if err := pullSecretSource.InjectCache(pullSecretCache); err != nil {
+		return fmt.Errorf("inject pull secret cache: %w", err)
+	}
+
+	if err := c.Watch(&pullSecretSource, enqueueAllSandboxes, reconcileSandbox.getPullSecretPredicate()); err != nil {
+		return fmt.Errorf("watch pull Secrets: %w", err)
+	}
+
+	// The sources of SandboxSharedResources are watched and read through a
+	// cache of each source namespace, rather than through the cache of the manager
+	sourceChanged := make(chan event.GenericEvent, 1)
+	sourceCaches := newSourceCaches(func(namespace string) (cache.Cache, error) {
+		return cache.New(mgr.GetConfig(), cache.Options{
+			Scheme:    mgr.GetScheme(),
+			Mapper:    mgr.GetRESTMapper(),
+			Namespace: namespace,
+		})
+	}, sourceChanged)
+
+	if err := mgr.Add(sourceCaches); err != nil {
+		return fmt.Errorf("add source caches: %w", err)
+	}
+
+	reconcileSandbox.sourceCaches = sourceCaches
+
+	if err := c.Watch(&source.Channel{Source: sourceChanged}, enqueueAllSandboxes); err != nil {
+		return fmt.Errorf("watch shared resource sources: %w", err)
+	}
+
+	enqueueNamespaceSandbox := &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(o handler.MapObject) []reconcile.Request {
+			sandboxName, ok := getSandboxName(o.Meta.GetNamespace())
+			if !ok {
+				return nil
+			}
+
+			return []reconcile.Request{
+				{NamespacedName: types.NamespacedName{Name: sandboxName}},
+			}
+		}),
+	}
+
+	serviceAccountPredicate := getSourcePredicate(func(object metav1.Object) bool {
+		_, ok := getSandboxName(object.GetNamespace())
+		return ok && len(reconcileSandbox.getOptions().PullSecretNames) > 0
+	})
+
+	if err := c.Watch(&source.Kind{Type: &corev1.ServiceAccount{}}, enqueueNamespaceSandbox, serviceAccountPredicate); err != nil {
+		return fmt.Errorf("watch ServiceAccounts: %w", err)
+	}
+
+	quotaRejectionPredicate := getSourcePredicate(func(object metav1.Object) bool {
+		_, ok := getSandboxName(object.GetNamespace())
+		return ok && isQuotaRejection(object)
+	})
+
+	// Quota rejections are watched through an informer that only lists and
+	// watches FailedCreate Events, rather than caching every Event in the cluster
+	clientset, err := kubernetes.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		return fmt.Errorf("new clientset: %w", err)
+	}
+
+	eventListWatch := toolscache.NewListWatchFromClient(clientset.CoreV1().RESTClient(), "events", metav1.NamespaceAll, fields.OneTermEqualSelector("reason", quotaRejectionReason))
+	eventInformer := toolscache.NewSharedIndexInformer(eventListWatch, &corev1.Event{}, 0, toolscache.Indexers{
+		toolscache.NamespaceIndex: toolscache.MetaNamespaceIndexFunc,
+	})
+	runEventInformer := manager.RunnableFunc(func(stop <-chan struct{}) error {
+		eventInformer.Run(stop)
+		return nil
+	})
+
+	if err := mgr.Add(runEventInformer); err != nil {
+		return fmt.Errorf("add quota rejection informer: %w", err)
+	}
+
+	reconcileSandbox.quotaRejections = eventInformer.GetIndexer()
+
+	if err := c.Watch(&source.Informer{Informer: eventInformer}, enqueueNamespaceSandbox, quotaRejectionPredicate); err != nil {
+		return fmt.Errorf("watch quota rejection Events: %w", err)
+	}
+
 	return nil
 }
 
+// getSourcePredicate filters create and update events to the objects that match isSource
+func getSourcePredicate(isSource func(metav1.Object) bool) predicate.Funcs {
+	sourcePredicate := predicate.Funcs{
+		CreateFunc:  func(e event.CreateEvent) bool { return isSource(e.Meta) },
+		UpdateFunc:  func(e event.UpdateEvent) bool { return isSource(e.MetaNew) },
+		DeleteFunc:  func(e event.DeleteEvent) bool { return false },
+		GenericFunc: func(e event.GenericEvent) bool { return isSource(e.Meta) },
+	}
+
+	return sourcePredicate
+}
+
+// getPullSecretPredicate filters Secret events to the source pull secrets
+func (r *ReconcileSandbox) getPullSecretPredicate() predicate.Funcs {
+	return getSourcePredicate(func(object metav1.Object) bool {
+		return r.getOptions().isPullSecret(object)
+	})
+}
+
+// getPullSecretReader returns the reader of the source pull secrets in the
+// namespace. The pull secret cache only holds the namespace the operator was
+// started with, so the API server is read when a config reload changed it.
+func (r *ReconcileSandbox) getPullSecretReader(namespace string) client.Reader {
+	if r.pullSecretCache == nil {
+		return r.client
+	}
+
+	if namespace != r.pullSecretCacheNamespace {
+		return r.apiReader
+	}
+
+	return r.pullSecretCache
+}
+
+// getSandboxRequests returns a reconcile request for every Sandbox in the cluster
+func (r *ReconcileSandbox) getSandboxRequests(ctx context.Context) []reconcile.Request {
+	var sandboxes operatorsv1alpha1.SandboxList
+	if err := r.client.List(ctx, &sandboxes); err != nil {
+		log.Error(err, "List Sandboxes")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, sandbox := range sandboxes.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: sandbox.Name},
+		})
+	}
+
+	return requests
+}
+
 // Reconcile syncs Sandbox changes to the cluster
 func (r *ReconcileSandbox) Reconcile(request reconcile.Request) (reconcile.Result, error) {
-	ctx := context.Background()
+	atomic.AddInt32(&r.inFlight, 1)
+	defer atomic.AddInt32(&r.inFlight, -1)
+
+	start := time.Now()
+	reconcileID := string(uuid.NewUUID())
+	r.reconcileStarts.Store(reconcileID, start)
+	defer r.reconcileStarts.Delete(reconcileID)
+
+	logger := log.WithValues("sandbox", request.Name, "reconcileID", reconcileID)
+	ctx := withLogger(context.Background(), logger)
 
-	if err := r.handleReconcile(ctx, request); err != nil {
-		log.Printf("reconcile Sandbox: %v\n", err)
+	result, err := r.handleReconcile(ctx, request)
+	if err != nil {
+		logger.Error(err, "Reconcile failed", "duration", time.Since(start).String())
 		return reconcile.Result{}, err
 	}
 
-	return reconcile.Result{}, nil
+	logger.V(1).Info("Reconciled", "duration", time.Since(start).String(), "requeueAfter", result.RequeueAfter.String())
+	return result, nil
+}
+
+// forget drops what is remembered about a Sandbox between reconciles once it
+// is deleted, including when it is deleted before its finalizer was added
+func (r *ReconcileSandbox) forget(name string) {
+	r.provisionFailures.Delete(name)
+	r.reportedRejections.Delete(name)
 }
 
-func (r *ReconcileSandbox) handleReconcile(ctx context.Context, request reconcile.Request) error {
+func (r *ReconcileSandbox) handleReconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
 	var sandbox operatorsv1alpha1.Sandbox
 	if err := r.client.Get(ctx, request.NamespacedName, &sandbox); err != nil {
 		if errors.IsNotFound(err) {
-			return nil
+			r.forget(request.Name)
+			return reconcile.Result{}, nil
 		}
 
-		return fmt.Errorf("get Sandbox: %w", err)
+		return reconcile.Result{}, fmt.Errorf("get Sandbox: %w", err)
 	}
 
+	ctx = withLogger(ctx, getLogger(ctx).WithValues("namespace", getNamespace(sandbox).Name))
+
+	if sandbox.DeletionTimestamp != nil {
+		return r.handleTeardown(ctx, sandbox)
+	}
+
+	if !ContainsString(sandbox.Finalizers, cleanupFinalizer) {
+		sandbox.Finalizers = append(sandbox.Finalizers, cleanupFinalizer)
+		if err := r.client.Update(ctx, &sandbox); err != nil {
+			return reconcile.Result{}, fmt.Errorf("add finalizer: %w", err)
+		}
+	}
+
+	if r.requiresCreationApproval(sandbox) {
+		approved, result, err := r.handleApproval(ctx, &sandbox)
+		if err != nil || !approved {
+			return result, err
+		}
+	}
+
+	// The size and budget are checked before provisioning so that the
+	// ResourceQuota is resized or hibernated in the same reconcile
+	now := time.Now()
+	status := sandbox.Status.DeepCopy()
+	if err := r.reconcileSize(ctx, &sandbox, now); err != nil {
+		r.recorder.Eventf(&sandbox, corev1.EventTypeWarning, "ProvisionFailed", "Failed to resize sandbox: %v", err)
+		return reconcile.Result{}, fmt.Errorf("reconcile size: %w", err)
+	}
+
+	if err := r.updateQuotaStatus(ctx, &sandbox); err != nil {
+		return reconcile.Result{}, fmt.Errorf("update quota status: %w", err)
+	}
+
+	if err := r.updateCostStatus(ctx, &sandbox, now); err != nil {
+		return reconcile.Result{}, fmt.Errorf("update cost status: %w", err)
+	}
+
+	overBudget, err := r.updateBudgetStatus(ctx, &sandbox, now)
+	if err != nil {
+		r.recorder.Eventf(&sandbox, corev1.EventTypeWarning, "ProvisionFailed", "Failed to check budget: %v", err)
+		return reconcile.Result{}, fmt.Errorf("update budget status: %w", err)
+	}
+
+	hibernated := overBudget || isHibernationRequested(sandbox)
+
+	resized := GetAppliedSize(sandbox) != status.Size
+	if err := r.handleProvision(ctx, sandbox, hibernated, resized); err != nil {
+		r.recorder.Eventf(&sandbox, corev1.EventTypeWarning, "ProvisionFailed", "Failed to provision sandbox: %v", err)
+		if previous, ok := r.provisionFailures.Load(sandbox.Name); !ok || previous != err.Error() {
+			r.provisionFailures.Store(sandbox.Name, err.Error())
+			r.notify(ctx, sandbox, NotificationProvisionFailed, fmt.Sprintf("Failed to provision sandbox: %v", err))
+		}
+
+		return reconcile.Result{}, err
+	}
+
+	r.provisionFailures.Delete(sandbox.Name)
+
+	if err := r.recordQuotaRejections(ctx, sandbox); err != nil {
+		return reconcile.Result{}, fmt.Errorf("record quota rejections: %w", err)
+	}
+
+	sandbox.Status.Phase = operatorsv1alpha1.SandboxPhaseActive
+	if hibernated {
+		sandbox.Status.Phase = operatorsv1alpha1.SandboxPhaseHibernated
+	}
+
+	sandbox.Status.ObservedGeneration = sandbox.Generation
+	if !equality.Semantic.DeepEqual(*status, sandbox.Status) {
+		if err := r.client.Status().Update(ctx, &sandbox); err != nil {
+			return reconcile.Result{}, fmt.Errorf("update status: %w", err)
+		}
+	}
+
+	// The resize and hibernation are only audited once they are saved in the
+	// status, as a reconcile that fails to save them makes them again
+	if status.Size != "" && status.Size != sandbox.Status.Size {
+		r.audit(ctx, sandbox, AuditResized, getLastEditor(sandbox), nil, map[string]string{"fromSize": status.Size, "toSize": sandbox.Status.Size})
+	}
+
+	// A Sandbox hibernated for its budget is hibernated and woken up by the
+	// operator, otherwise by the user that set or removed the annotation
+	wasHibernated := status.Phase == operatorsv1alpha1.SandboxPhaseHibernated
+	budgetCondition, _ := getCondition(*status, operatorsv1alpha1.SandboxConditionBudgetExceeded)
+	wasOverBudget := budgetCondition.Status == corev1.ConditionTrue
+	switch {
+	case hibernated && !wasHibernated && overBudget:
+		r.audit(ctx, sandbox, AuditHibernated, operatorActor, nil, map[string]string{"reason": "BudgetExceeded"})
+	case hibernated && !wasHibernated:
+		r.recorder.Event(&sandbox, corev1.EventTypeNormal, "Hibernated", "The sandbox was hibernated on request, the workloads were scaled down")
+		r.audit(ctx, sandbox, AuditHibernated, getLastEditor(sandbox), nil, map[string]string{"reason": "Requested"})
+	case !hibernated && wasHibernated && wasOverBudget:
+		r.audit(ctx, sandbox, AuditWoken, operatorActor, nil, map[string]string{"reason": "BudgetRaised"})
+	case !hibernated && wasHibernated:
+		r.recorder.Event(&sandbox, corev1.EventTypeNormal, "Woken", "The sandbox was woken up on request, the workloads were scaled up")
+		r.audit(ctx, sandbox, AuditWoken, getLastEditor(sandbox), nil, map[string]string{"reason": "Requested"})
+	}
+
+	options := r.getOptions()
+	requeueAfter := options.ResyncPeriod
+	if options.Prices.enabled() && (requeueAfter == 0 || requeueAfter > costAccrualPeriod) {
+		requeueAfter = costAccrualPeriod
+	}
+
+	if requeueAfter > 0 {
+		return reconcile.Result{RequeueAfter: wait.Jitter(requeueAfter, 0.1)}, nil
+	}
+
+	return reconcile.Result{}, nil
+}
+
+func (r *ReconcileSandbox) handleProvision(ctx context.Context, sandbox operatorsv1alpha1.Sandbox, hibernated bool, resized bool) error {
 	namespace := getNamespace(sandbox)
-	_, err := ctrl.CreateOrUpdate(ctx, r.client, &namespace, func() error {
+	namespaceLabels := namespace.Labels
+	start := time.Now()
+	result, err := ctrl.CreateOrUpdate(ctx, r.client, &namespace, func() error {
+		namespace.Labels = mergeLabels(namespace.Labels, namespaceLabels)
 		return controllerutil.SetControllerReference(&sandbox, &namespace, r.scheme)
 	})
+	observeStep("Namespace", start, err)
 	if err != nil {
 		return fmt.Errorf("reconcile Namespace: %w", err)
 	}
 
+	logOperation(ctx, "Namespace", namespace.Name, result)
+	created := !r.recordDrift(sandbox, "Namespace", namespace.Name, result) && result == controllerutil.OperationResultCreated
+	if created {
+		r.recorder.Eventf(&sandbox, corev1.EventTypeNormal, "NamespaceCreated", "Created namespace %s", namespace.Name)
+	}
+
 	resourceQuota := getResourceQuota(sandbox)
-	_, err = ctrl.CreateOrUpdate(ctx, r.client, &resourceQuota, func() error {
+	if hibernated {
+		resourceQuota.Spec.Hard[corev1.ResourcePods] = resource.MustParse("0")
+	}
+
+	resourceQuotaLabels := resourceQuota.Labels
+	resourceQuotaSpec := resourceQuota.Spec
+	start = time.Now()
+	result, err = ctrl.CreateOrUpdate(ctx, r.client, &resourceQuota, func() error {
+		resourceQuota.Labels = mergeLabels(resourceQuota.Labels, resourceQuotaLabels)
+		resourceQuota.Spec = resourceQuotaSpec
 		return controllerutil.SetControllerReference(&sandbox, &resourceQuota, r.scheme)
 	})
+	observeStep("ResourceQuota", start, err)
 	if err != nil {
 		return fmt.Errorf("reconcile ResourceQuota: %w", err)
 	}
 
+	logOperation(ctx, "ResourceQuota", resourceQuota.Name, result)
+
+	// Hibernating, waking or approving a resize of the sandbox changes the
+	// quota without changing the Sandbox
+	wasHibernated := sandbox.Status.Phase == operatorsv1alpha1.SandboxPhaseHibernated
+	if hibernated != wasHibernated && result == controllerutil.OperationResultUpdated {
+		r.recorder.Eventf(&sandbox, corev1.EventTypeNormal, "QuotaUpdated", "Updated ResourceQuota %s for hibernation", resourceQuota.Name)
+	} else if (resized || !r.recordDrift(sandbox, "ResourceQuota", resourceQuota.Name, result)) && result == controllerutil.OperationResultUpdated {
+		r.recorder.Eventf(&sandbox, corev1.EventTypeNormal, "QuotaUpdated", "Updated ResourceQuota %s for size %s", resourceQuota.Name, GetAppliedSize(sandbox))
+	}
+
+	if hibernated {
+		if err := r.scaleWorkloads(ctx, sandbox, true); err != nil {
+			return fmt.Errorf("scale down workloads: %w", err)
+		}
+	} else if wasHibernated {
+		if err := r.scaleWorkloads(ctx, sandbox, false); err != nil {
+			return fmt.Errorf("scale up workloads: %w", err)
+		}
+	}
+
 	role := getRole(sandbox)
-	_, err = ctrl.CreateOrUpdate(ctx, r.client, &role, func() error {
+	roleLabels := role.Labels
+	roleRules := role.Rules
+	start = time.Now()
+	result, err = ctrl.CreateOrUpdate(ctx, r.client, &role, func() error {
+		role.Labels = mergeLabels(role.Labels, roleLabels)
+		role.Rules = roleRules
 		return controllerutil.SetControllerReference(&sandbox, &role, r.scheme)
 	})
+	observeStep("Role", start, err)
 	if err != nil {
 		return fmt.Errorf("reconcile Role: %w", err)
 	}
 
-	roleBinding := getRoleBinding(sandbox)
-	_, err = ctrl.CreateOrUpdate(ctx, r.client, &roleBinding, func() error {
-		subjects, err := r.subjectsClient.Subjects(ctx, sandbox.Spec.Owners)
-		if err != nil {
-			return fmt.Errorf("get subjects: %w", err)
-		}
+	logOperation(ctx, "Role", role.Name, result)
+	r.recordDrift(sandbox, "Role", role.Name, result)
+
+	subjects, err := r.getSubjectsClient().Subjects(ctx, sandbox.Spec.Owners)
+	if err != nil {
+		return fmt.Errorf("get subjects: %w", err)
+	}
+
+	if len(subjects) < len(sandbox.Spec.Owners) {
+		r.recorder.Eventf(&sandbox, corev1.EventTypeWarning, "OwnersUnresolved", "Resolved %d of %d owners, unresolved owners were not granted access", len(subjects), len(sandbox.Spec.Owners))
+	}
 
+	if created {
+		r.audit(ctx, sandbox, AuditCreated, sandbox.Annotations[createdByAnnotation], subjects, map[string]string{"size": GetAppliedSize(sandbox)})
+		r.notify(ctx, sandbox, NotificationCreated, fmt.Sprintf("Created namespace %s", namespace.Name))
+	}
+
+	roleBinding := getRoleBinding(sandbox)
+	roleBindingLabels := roleBinding.Labels
+	var previousSubjects []rbacv1.Subject
+	start = time.Now()
+	result, err = ctrl.CreateOrUpdate(ctx, r.client, &roleBinding, func() error {
+		previousSubjects = roleBinding.Subjects
+		roleBinding.Labels = mergeLabels(roleBinding.Labels, roleBindingLabels)
 		roleBinding.Subjects = subjects
 		return controllerutil.SetControllerReference(&sandbox, &roleBinding, r.scheme)
 	})
+	observeStep("RoleBinding", start, err)
 	if err != nil {
 		return fmt.Errorf("reconcile RoleBinding: %w", err)
 	}
 
+	logOperation(ctx, "RoleBinding", roleBinding.Name, result)
+	if !r.recordDrift(sandbox, "RoleBinding", roleBinding.Name, result) && result == controllerutil.OperationResultUpdated {
+		added, removed := getSubjectChanges(previousSubjects, subjects)
+		if len(added) > 0 {
+			r.audit(ctx, sandbox, AuditOwnerAdded, getLastEditor(sandbox), added, nil)
+		}
+
+		if len(removed) > 0 {
+			r.audit(ctx, sandbox, AuditOwnerRemoved, getLastEditor(sandbox), removed, nil)
+		}
+	}
+
 	clusterRole := getClusterRole(sandbox)
-	_, err = ctrl.CreateOrUpdate(ctx, r.client, &clusterRole, func() error {
+	clusterRoleLabels := clusterRole.Labels
+	clusterRoleRules := clusterRole.Rules
+	start = time.Now()
+	result, err = ctrl.CreateOrUpdate(ctx, r.client, &clusterRole, func() error {
+		clusterRole.Labels = mergeLabels(clusterRole.Labels, clusterRoleLabels)
+		clusterRole.Rules = clusterRoleRules
 		return controllerutil.SetControllerReference(&sandbox, &clusterRole, r.scheme)
 	})
+	observeStep("ClusterRole", start, err)
 	if err != nil {
 		return fmt.Errorf("reconcile ClusterRole: %w", err)
 	}
 
-	clusterRoleBinding := getClusterRoleBinding(sandbox)
-	_, err = ctrl.CreateOrUpdate(ctx, r.client, &clusterRoleBinding, func() error {
-		subjects, err := r.subjectsClient.Subjects(ctx, sandbox.Spec.Owners)
-		if err != nil {
-			return fmt.Errorf("get subjects: %w", err)
-		}
+	logOperation(ctx, "ClusterRole", clusterRole.Name, result)
+	r.recordDrift(sandbox, "ClusterRole", clusterRole.Name, result)
 
+	clusterRoleBinding := getClusterRoleBinding(sandbox)
+	clusterRoleBindingLabels := clusterRoleBinding.Labels
+	start = time.Now()
+	result, err = ctrl.CreateOrUpdate(ctx, r.client, &clusterRoleBinding, func() error {
+		clusterRoleBinding.Labels = mergeLabels(clusterRoleBinding.Labels, clusterRoleBindingLabels)
 		clusterRoleBinding.Subjects = subjects
 		return controllerutil.SetControllerReference(&sandbox, &clusterRoleBinding, r.scheme)
 	})
+	observeStep("ClusterRoleBinding", start, err)
 	if err != nil {
 		return fmt.Errorf("reconcile ClusterRoleBinding: %w", err)
 	}
 
-	if os.Getenv("PULL_SECRET_NAME") != "" {
-		secretName := os.Getenv("PULL_SECRET_NAME")
-		secretData, err := getDockerSecretData(ctx, r.client, secretName)
+	logOperation(ctx, "ClusterRoleBinding", clusterRoleBinding.Name, result)
+	r.recordDrift(sandbox, "ClusterRoleBinding", clusterRoleBinding.Name, result)
+
+	start = time.Now()
+	err = r.reconcilePullSecrets(ctx, sandbox)
+	observeStep("PullSecrets", start, err)
+	if err != nil {
+		r.recorder.Eventf(&sandbox, corev1.EventTypeWarning, "PullSecretFailed", "Failed to sync pull secrets: %v", err)
+		return fmt.Errorf("reconcile pull secrets: %w", err)
+	}
+
+	start = time.Now()
+	err = r.reconcileSharedResources(ctx, sandbox)
+	observeStep("SharedResources", start, err)
+	if err != nil {
+		return fmt.Errorf("reconcile shared resources: %w", err)
+	}
+
+	return nil
+}
+
+// recordDrift records an Event on the Sandbox when a resource that had already
+// been provisioned had to be created or updated without the Sandbox changing,
+// and reports whether the change was drift
+func (r *ReconcileSandbox) recordDrift(sandbox operatorsv1alpha1.Sandbox, kind string, name string, result controllerutil.OperationResult) bool {
+	if result == controllerutil.OperationResultNone {
+		return false
+	}
+
+	if !isProvisioned(sandbox) || sandbox.Status.ObservedGeneration != sandbox.Generation {
+		return false
+	}
+
+	if result == controllerutil.OperationResultCreated {
+		r.recorder.Eventf(&sandbox, corev1.EventTypeWarning, "DriftReverted", "Recreated %s %s after it was deleted", kind, name)
+	} else {
+		r.recorder.Eventf(&sandbox, corev1.EventTypeWarning, "DriftReverted", "Reverted changes made to %s %s", kind, name)
+	}
+
+	return true
+}
+
+// isProvisioned reports whether the resources of the Sandbox have been provisioned
+func isProvisioned(sandbox operatorsv1alpha1.Sandbox) bool {
+	return sandbox.Status.Phase == operatorsv1alpha1.SandboxPhaseActive || sandbox.Status.Phase == operatorsv1alpha1.SandboxPhaseHibernated
+}
+
+// mergeLabels sets the desired labels on top of the existing labels of an object
+func mergeLabels(existing map[string]string, desired map[string]string) map[string]string {
+	labels := make(map[string]string)
+	for key, value := range existing {
+		labels[key] = value
+	}
+
+	for key, value := range desired {
+		labels[key] = value
+	}
+
+	return labels
+}
+
+func (r *ReconcileSandbox) reconcilePullSecrets(ctx context.Context, sandbox operatorsv1alpha1.Sandbox) error {
+	options := r.getOptions()
+	if len(options.PullSecretNames) == 0 {
+		return nil
+	}
+
+	for _, secretName := range options.PullSecretNames {
+		pullSecretSynced.WithLabelValues(sandbox.Name, secretName).Set(0)
+
+		secretData, err := getDockerSecretData(ctx, r.getPullSecretReader(options.PullSecretNamespace), options.PullSecretNamespace, secretName)
 		if err != nil {
 			return fmt.Errorf("get secret data: %w", err)
 		}
 
 		secret := getDockerSecret(sandbox, secretName, secretData)
-		_, err = ctrl.CreateOrUpdate(ctx, r.client, &secret, func() error {
+		result, err := ctrl.CreateOrUpdate(ctx, r.client, &secret, func() error {
+			secret.Data = map[string][]byte{
+				corev1.DockerConfigJsonKey: secretData,
+			}
+
 			return controllerutil.SetControllerReference(&sandbox, &secret, r.scheme)
 		})
 		if err != nil {
 			return fmt.Errorf("reconcile docker Secret: %w", err)
 		}
 
-		var defaultServiceAccount corev1.ServiceAccount
-		if err := r.client.Get(ctx, types.NamespacedName{Name: "default", Namespace: namespace.Name}, &defaultServiceAccount); err != nil {
-			return fmt.Errorf("get default service account: %w", err)
+		pullSecretSynced.WithLabelValues(sandbox.Name, secretName).Set(1)
+		logOperation(ctx, "Secret", secret.Name, result)
+
+		// Updates to the copy are expected when the source secret is rotated
+		if result == controllerutil.OperationResultCreated {
+			r.recordDrift(sandbox, "Secret", secret.Name, result)
+		}
+	}
+
+	namespace := getNamespace(sandbox)
+
+	var serviceAccounts corev1.ServiceAccountList
+	if err := r.client.List(ctx, &serviceAccounts, client.InNamespace(namespace.Name)); err != nil {
+		return fmt.Errorf("list service accounts: %w", err)
+	}
+
+	for i := range serviceAccounts.Items {
+		serviceAccount := &serviceAccounts.Items[i]
+		if serviceAccount.Annotations[skipPullSecretsAnnotation] == "true" {
+			continue
 		}
 
-		patchBytes, err := getPatchBytes(secretName)
-		if err != nil {
-			return fmt.Errorf("get patch bytes: %w", err)
+		imagePullSecrets := getImagePullSecrets(serviceAccount.ImagePullSecrets, options.PullSecretNames)
+		if len(imagePullSecrets) == len(serviceAccount.ImagePullSecrets) {
+			continue
 		}
 
-		patch := client.ConstantPatch(types.StrategicMergePatchType, patchBytes)
-		if err := r.client.Patch(ctx, &defaultServiceAccount, patch, &client.PatchOptions{}); err != nil {
-			return fmt.Errorf("patch service account: %w", err)
+		patch := client.MergeFrom(serviceAccount.DeepCopy())
+		serviceAccount.ImagePullSecrets = imagePullSecrets
+		if err := r.client.Patch(ctx, serviceAccount, patch); err != nil {
+			return fmt.Errorf("patch service account %s: %w", serviceAccount.Name, err)
 		}
 	}
 
 	return nil
 }
 
-func getPatchBytes(secretName string) ([]byte, error) {
-	type imagePullSecretsPatch struct {
-		ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
-	}
+// getImagePullSecrets merges the given secret names into the existing image pull
+// secrets of a service account, keeping any secrets that were already present
+func getImagePullSecrets(existing []corev1.LocalObjectReference, secretNames []string) []corev1.LocalObjectReference {
+	imagePullSecrets := append([]corev1.LocalObjectReference{}, existing...)
+	for _, secretName := range secretNames {
+		found := false
+		for _, imagePullSecret := range existing {
+			if imagePullSecret.Name == secretName {
+				found = true
+				break
+			}
+		}
 
-	patch := imagePullSecretsPatch{
-		ImagePullSecrets: []corev1.LocalObjectReference{
-			{Name: secretName},
-		},
+		if !found {
+			imagePullSecrets = append(imagePullSecrets, corev1.LocalObjectReference{Name: secretName})
+		}
 	}
 
-	patchString, err := json.Marshal(patch)
-	if err != nil {
-		return nil, fmt.Errorf("patching default service account: %w", err)
+	return imagePullSecrets
+}
+
+// getSandboxName returns the name of the Sandbox that owns the given namespace
+func getSandboxName(namespace string) (string, bool) {
+	if !strings.HasPrefix(namespace, "sandbox-") {
+		return "", false
 	}
 
-	return patchString, nil
+	return strings.TrimPrefix(namespace, "sandbox-"), true
 }
 
 func getNamespace(sandbox operatorsv1alpha1.Sandbox) corev1.Namespace {
@@ -374,25 +1025,26 @@ func getClusterRoleBinding(sandbox operatorsv1alpha1.Sandbox) rbacv1.ClusterRole
 }
 
 func getResourceQuota(sandbox operatorsv1alpha1.Sandbox) corev1.ResourceQuota {
-	var resourceQuotaSpec corev1.ResourceQuotaSpec
-	if strings.EqualFold(sandbox.Spec.Size, "large") {
-		resourceQuotaSpec = getLargeResourceQuotaSpec()
-	} else {
-		resourceQuotaSpec = getSmallResourceQuotaSpec()
-	}
-
 	resourceQuota := corev1.ResourceQuota{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "sandbox-" + sandbox.Name + "-resourcequota",
 			Namespace: "sandbox-" + sandbox.Name,
 			Labels:    getCommonLabels(),
 		},
-		Spec: resourceQuotaSpec,
+		Spec: getResourceQuotaSpec(GetAppliedSize(sandbox)),
 	}
 
 	return resourceQuota
 }
 
+func getResourceQuotaSpec(size string) corev1.ResourceQuotaSpec {
+	if size == "large" {
+		return getLargeResourceQuotaSpec()
+	}
+
+	return getSmallResourceQuotaSpec()
+}
+
 func getLargeResourceQuotaSpec() corev1.ResourceQuotaSpec {
 	resourceQuotaSpec := corev1.ResourceQuotaSpec{
 		Hard: corev1.ResourceList{
@@ -423,16 +1075,17 @@ func getSmallResourceQuotaSpec() corev1.ResourceQuotaSpec {
 	return resourceQuotaSpec
 }
 
-func getDockerSecretData(ctx context.Context, client client.Client, secretName string) ([]byte, error) {
-	var secretNamespace string
-	if os.Getenv("PULL_SECRET_NAMESPACE") != "" {
-		secretNamespace = os.Getenv("PULL_SECRET_NAMESPACE")
-	} else {
-		secretNamespace = "default"
+func (o Options) isPullSecret(object metav1.Object) bool {
+	if object.GetNamespace() != o.PullSecretNamespace {
+		return false
 	}
 
+	return ContainsString(o.PullSecretNames, object.GetName())
+}
+
+func getDockerSecretData(ctx context.Context, client client.Reader, namespace string, secretName string) ([]byte, error) {
 	var dockerSecret corev1.Secret
-	if err := client.Get(ctx, types.NamespacedName{Name: secretName, Namespace: secretNamespace}, &dockerSecret); err != nil {
+	if err := client.Get(ctx, types.NamespacedName{Name: secretName, Namespace: namespace}, &dockerSecret); err != nil {
 		return nil, fmt.Errorf("get docker secret: %w", err)
 	}
 
@@ -489,15 +1142,15 @@ func getSubjects(users []string) []rbacv1.Subject {
 	return subjects
 }
 
-func newSubjectsClient() (SubjectsClient, error) {
-	if os.Getenv("AZURE_TENANT_ID") == "" {
-		return DefaultSubjects{}, nil
+func newSubjectsClient(options Options) (SubjectsClient, error) {
+	if options.IdentityProvider != identityProviderAzure {
+		return instrumentedSubjects{backend: "default", subjectsClient: DefaultSubjects{}}, nil
 	}
 
-	azureSubjects, err := NewAzureSubjectsClient()
+	azureSubjects, err := NewAzureSubjectsClient(options.AzureTenantID)
 	if err != nil {
 		return nil, fmt.Errorf("new azure subjects: %w", err)
 	}
 
-	return azureSubjects, nil
+	return instrumentedSubjects{backend: "azure", subjectsClient: azureSubjects}, nil
 }
@@ -4,17 +4,25 @@ package controller
 
 import (
 	"context"
-	"log"
+	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/plexsystems/sandbox-operator/apis"
 	operatorsv1alpha1 "github.com/plexsystems/sandbox-operator/apis/operators/v1alpha1"
 
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
@@ -22,14 +30,12 @@ func TestSandboxController_ByDefault_CreatesSandbox(t *testing.T) {
 	ctx := context.TODO()
 
 	s := scheme.Scheme
-	s.AddKnownTypes(operatorsv1alpha1.SchemeGroupVersion, &operatorsv1alpha1.Sandbox{})
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatalf("add scheme: %v", err)
+	}
 
 	fakeClient := fake.NewFakeClientWithScheme(s)
-	r := ReconcileSandbox{
-		client:         fakeClient,
-		scheme:         s,
-		subjectsClient: &DefaultSubjects{},
-	}
+	r := NewReconcileSandbox(fakeClient, fakeClient, s, &DefaultSubjects{}, &record.FakeRecorder{})
 
 	sandbox := operatorsv1alpha1.Sandbox{
 		ObjectMeta: metav1.ObjectMeta{
@@ -48,7 +54,7 @@ func TestSandboxController_ByDefault_CreatesSandbox(t *testing.T) {
 	}
 
 	if _, err := r.Reconcile(request); err != nil {
-		log.Fatalf("reconcile sandbox: %v", err)
+		t.Fatalf("reconcile sandbox: %v", err)
 	}
 
 	namespace := getNamespace(sandbox)
@@ -86,14 +92,12 @@ func TestSandboxController_AddOwner_UpdatesRoleAndClusterRoleBindings(t *testing
 	ctx := context.TODO()
 
 	s := scheme.Scheme
-	s.AddKnownTypes(operatorsv1alpha1.SchemeGroupVersion, &operatorsv1alpha1.Sandbox{})
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatalf("add scheme: %v", err)
+	}
 
 	fakeClient := fake.NewFakeClientWithScheme(s)
-	r := ReconcileSandbox{
-		client:         fakeClient,
-		scheme:         s,
-		subjectsClient: DefaultSubjects{},
-	}
+	r := NewReconcileSandbox(fakeClient, fakeClient, s, DefaultSubjects{}, &record.FakeRecorder{})
 
 	sandbox := operatorsv1alpha1.Sandbox{
 		ObjectMeta: metav1.ObjectMeta{
@@ -115,7 +119,7 @@ func TestSandboxController_AddOwner_UpdatesRoleAndClusterRoleBindings(t *testing
 	}
 
 	if _, err := r.Reconcile(request); err != nil {
-		log.Fatalf("reconcile sandbox: %v", err)
+		t.Fatalf("reconcile sandbox: %v", err)
 	}
 
 	roleBinding := getRoleBinding(sandbox)
@@ -165,3 +169,374 @@ func TestSandboxController_AddOwner_UpdatesRoleAndClusterRoleBindings(t *testing
 		t.Errorf("expected subject to be added to ClusterRoleBinding but it was not: %v", foundClusterRoleBinding)
 	}
 }
+
+func TestSandboxController_PullSecretRotated_UpdatesSecretCopies(t *testing.T) {
+	ctx := context.TODO()
+
+	s := scheme.Scheme
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatalf("add scheme: %v", err)
+	}
+
+	fakeClient := fake.NewFakeClientWithScheme(s)
+	r := NewReconcileSandbox(fakeClient, fakeClient, s, DefaultSubjects{}, &record.FakeRecorder{})
+	r.options.PullSecretNames = []string{"registry-a", "registry-b"}
+
+	sandbox := operatorsv1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+	}
+
+	if err := r.client.Create(ctx, &sandbox); err != nil {
+		t.Fatalf("create sandbox: %v", err)
+	}
+
+	for _, secretName := range []string{"registry-a", "registry-b"} {
+		secret := corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      secretName,
+				Namespace: "default",
+			},
+			Data: map[string][]byte{
+				corev1.DockerConfigJsonKey: []byte("old"),
+			},
+			Type: corev1.SecretTypeDockerConfigJson,
+		}
+
+		if err := r.client.Create(ctx, &secret); err != nil {
+			t.Fatalf("create pull secret: %v", err)
+		}
+	}
+
+	defaultServiceAccount := corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "default",
+			Namespace: "sandbox-test",
+		},
+	}
+
+	if err := r.client.Create(ctx, &defaultServiceAccount); err != nil {
+		t.Fatalf("create service account: %v", err)
+	}
+
+	request := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name: sandbox.Name,
+		},
+	}
+
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("reconcile sandbox: %v", err)
+	}
+
+	var sourceSecret corev1.Secret
+	if err := r.client.Get(ctx, types.NamespacedName{Name: "registry-a", Namespace: "default"}, &sourceSecret); err != nil {
+		t.Fatalf("get pull secret: %v", err)
+	}
+
+	sourceSecret.Data[corev1.DockerConfigJsonKey] = []byte("new")
+	if err := r.client.Update(ctx, &sourceSecret); err != nil {
+		t.Fatalf("update pull secret: %v", err)
+	}
+
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("reconcile sandbox: %v", err)
+	}
+
+	var copiedSecret corev1.Secret
+	if err := r.client.Get(ctx, types.NamespacedName{Name: "registry-a", Namespace: "sandbox-test"}, &copiedSecret); err != nil {
+		t.Fatalf("expected pull secret to be copied but it was not: %v", err)
+	}
+
+	if string(copiedSecret.Data[corev1.DockerConfigJsonKey]) != "new" {
+		t.Errorf("expected pull secret copy to be updated but it was not: %s", copiedSecret.Data[corev1.DockerConfigJsonKey])
+	}
+
+	var foundServiceAccount corev1.ServiceAccount
+	if err := r.client.Get(ctx, types.NamespacedName{Name: "default", Namespace: "sandbox-test"}, &foundServiceAccount); err != nil {
+		t.Fatalf("get service account: %v", err)
+	}
+
+	if len(foundServiceAccount.ImagePullSecrets) != 2 {
+		t.Errorf("expected both pull secrets on the default service account but found: %v", foundServiceAccount.ImagePullSecrets)
+	}
+}
+
+func TestSandboxController_PullSecretEvent_EnqueuesAllSandboxes(t *testing.T) {
+	ctx := context.TODO()
+
+	s := scheme.Scheme
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatalf("add scheme: %v", err)
+	}
+
+	sandboxes := []runtime.Object{
+		&operatorsv1alpha1.Sandbox{ObjectMeta: metav1.ObjectMeta{Name: "a"}},
+		&operatorsv1alpha1.Sandbox{ObjectMeta: metav1.ObjectMeta{Name: "b"}},
+	}
+
+	fakeClient := fake.NewFakeClientWithScheme(s, sandboxes...)
+	r := NewReconcileSandbox(fakeClient, fakeClient, s, DefaultSubjects{}, &record.FakeRecorder{})
+	r.options.PullSecretNames = []string{"registry"}
+
+	enqueueAllSandboxes := &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(handler.MapObject) []reconcile.Request {
+			return r.getSandboxRequests(ctx)
+		}),
+	}
+
+	getEvent := func(name string, namespace string) event.UpdateEvent {
+		secret := corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+		}
+
+		return event.UpdateEvent{MetaOld: &secret, ObjectOld: &secret, MetaNew: &secret, ObjectNew: &secret}
+	}
+
+	pullSecretPredicate := r.getPullSecretPredicate()
+	if pullSecretPredicate.Update(getEvent("other", "default")) {
+		t.Errorf("expected a Secret that is not a pull secret to be filtered")
+	}
+
+	if pullSecretPredicate.Update(getEvent("registry", "other")) {
+		t.Errorf("expected a Secret outside the pull secret namespace to be filtered")
+	}
+
+	rotated := getEvent("registry", "default")
+	if !pullSecretPredicate.Update(rotated) {
+		t.Fatalf("expected the pull secret to pass the predicate")
+	}
+
+	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	defer queue.ShutDown()
+
+	enqueueAllSandboxes.Update(rotated, queue)
+	if queue.Len() != len(sandboxes) {
+		t.Fatalf("expected every Sandbox to be enqueued but %d were", queue.Len())
+	}
+}
+
+func TestSandboxController_PullSecrets_MergedIntoAllServiceAccounts(t *testing.T) {
+	ctx := context.TODO()
+
+	s := scheme.Scheme
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatalf("add scheme: %v", err)
+	}
+
+	pullSecret := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "registry",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			corev1.DockerConfigJsonKey: []byte("{}"),
+		},
+		Type: corev1.SecretTypeDockerConfigJson,
+	}
+
+	appServiceAccount := corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "app",
+			Namespace: "sandbox-test",
+		},
+		ImagePullSecrets: []corev1.LocalObjectReference{
+			{Name: "own-registry"},
+		},
+	}
+
+	skippedServiceAccount := corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "skipped",
+			Namespace: "sandbox-test",
+			Annotations: map[string]string{
+				skipPullSecretsAnnotation: "true",
+			},
+		},
+	}
+
+	fakeClient := fake.NewFakeClientWithScheme(s, &pullSecret, &appServiceAccount, &skippedServiceAccount)
+	r := NewReconcileSandbox(fakeClient, fakeClient, s, DefaultSubjects{}, &record.FakeRecorder{})
+	r.options.PullSecretNames = []string{"registry"}
+
+	sandbox := operatorsv1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+	}
+
+	if err := r.client.Create(ctx, &sandbox); err != nil {
+		t.Fatalf("create sandbox: %v", err)
+	}
+
+	request := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name: sandbox.Name,
+		},
+	}
+
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("reconcile sandbox: %v", err)
+	}
+
+	var foundAppServiceAccount corev1.ServiceAccount
+	if err := r.client.Get(ctx, types.NamespacedName{Name: "app", Namespace: "sandbox-test"}, &foundAppServiceAccount); err != nil {
+		t.Fatalf("get service account: %v", err)
+	}
+
+	expected := []corev1.LocalObjectReference{{Name: "own-registry"}, {Name: "registry"}}
+	if !reflect.DeepEqual(foundAppServiceAccount.ImagePullSecrets, expected) {
+		t.Errorf("expected image pull secrets to be merged into %v but found: %v", expected, foundAppServiceAccount.ImagePullSecrets)
+	}
+
+	var foundSkippedServiceAccount corev1.ServiceAccount
+	if err := r.client.Get(ctx, types.NamespacedName{Name: "skipped", Namespace: "sandbox-test"}, &foundSkippedServiceAccount); err != nil {
+		t.Fatalf("get service account: %v", err)
+	}
+
+	if len(foundSkippedServiceAccount.ImagePullSecrets) != 0 {
+		t.Errorf("expected opted out service account to be left alone but found: %v", foundSkippedServiceAccount.ImagePullSecrets)
+	}
+}
+
+func TestSandboxController_ResourceQuotaChanged_RevertsDrift(t *testing.T) {
+	ctx := context.TODO()
+
+	s := scheme.Scheme
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatalf("add scheme: %v", err)
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	fakeClient := fake.NewFakeClientWithScheme(s)
+	r := NewReconcileSandbox(fakeClient, fakeClient, s, DefaultSubjects{}, recorder)
+
+	sandbox := operatorsv1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+	}
+
+	if err := r.client.Create(ctx, &sandbox); err != nil {
+		t.Fatalf("create sandbox: %v", err)
+	}
+
+	request := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name: sandbox.Name,
+		},
+	}
+
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("reconcile sandbox: %v", err)
+	}
+
+	resourceQuota := getResourceQuota(sandbox)
+
+	var foundResourceQuota corev1.ResourceQuota
+	if err := r.client.Get(ctx, types.NamespacedName{Name: resourceQuota.Name, Namespace: resourceQuota.Namespace}, &foundResourceQuota); err != nil {
+		t.Fatalf("get resource quota: %v", err)
+	}
+
+	foundResourceQuota.Spec.Hard[corev1.ResourceLimitsCPU] = resource.MustParse("64")
+	if err := r.client.Update(ctx, &foundResourceQuota); err != nil {
+		t.Fatalf("update resource quota: %v", err)
+	}
+
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("reconcile sandbox: %v", err)
+	}
+
+	if err := r.client.Get(ctx, types.NamespacedName{Name: resourceQuota.Name, Namespace: resourceQuota.Namespace}, &foundResourceQuota); err != nil {
+		t.Fatalf("get resource quota: %v", err)
+	}
+
+	expected := resourceQuota.Spec.Hard[corev1.ResourceLimitsCPU]
+	actual := foundResourceQuota.Spec.Hard[corev1.ResourceLimitsCPU]
+	if actual.Cmp(expected) != 0 {
+		t.Errorf("expected ResourceQuota limits.cpu to be reverted to %s but was %s", expected.String(), actual.String())
+	}
+
+	if !hasEvent(recorder, "DriftReverted") {
+		t.Errorf("expected a DriftReverted event but none was recorded")
+	}
+}
+
+type unresolvedSubjects struct{}
+
+func (unresolvedSubjects) Subjects(ctx context.Context, users []string) ([]rbacv1.Subject, error) {
+	return getSubjects(users[1:]), nil
+}
+
+func TestSandboxController_OwnersUnresolved_RecordsEvents(t *testing.T) {
+	ctx := context.TODO()
+
+	s := scheme.Scheme
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatalf("add scheme: %v", err)
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	fakeClient := fake.NewFakeClientWithScheme(s)
+	r := NewReconcileSandbox(fakeClient, fakeClient, s, unresolvedSubjects{}, recorder)
+
+	sandbox := operatorsv1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+		Spec: operatorsv1alpha1.SandboxSpec{
+			Owners: []string{"missing@plex.dev", "found@plex.dev"},
+		},
+	}
+
+	if err := r.client.Create(ctx, &sandbox); err != nil {
+		t.Fatalf("create sandbox: %v", err)
+	}
+
+	request := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name: sandbox.Name,
+		},
+	}
+
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("reconcile sandbox: %v", err)
+	}
+
+	events := getEvents(recorder)
+	for _, reason := range []string{"NamespaceCreated", "OwnersUnresolved"} {
+		if !containsReason(events, reason) {
+			t.Errorf("expected a %s event but got: %v", reason, events)
+		}
+	}
+}
+
+func hasEvent(recorder *record.FakeRecorder, reason string) bool {
+	return containsReason(getEvents(recorder), reason)
+}
+
+func getEvents(recorder *record.FakeRecorder) []string {
+	var events []string
+	for {
+		select {
+		case event := <-recorder.Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func containsReason(events []string, reason string) bool {
+	for _, event := range events {
+		if strings.Contains(event, " "+reason+" ") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,488 @@
+package controller
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	operatorsv1alpha1 "github.com/plexsystems/sandbox-operator/apis/operators/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	toolscache "k8s.io/client-go/tools/cache"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+const sharedResourceLabel = "operators.plex.dev/shared-resource"
+
+func (r *ReconcileSandbox) reconcileSharedResources(ctx context.Context, sandbox operatorsv1alpha1.Sandbox) error {
+	var sharedResources operatorsv1alpha1.SandboxSharedResourceList
+	if err := r.client.List(ctx, &sharedResources); err != nil {
+		return fmt.Errorf("list SandboxSharedResources: %w", err)
+	}
+
+	if r.sourceCaches != nil {
+		r.sourceCaches.setSources(sharedResources.Items)
+	}
+
+	// A SandboxSharedResource that cannot be copied is reported on its own
+	// status, so that it does not hold up the provisioning of every Sandbox
+	sharedSecrets := make(map[string]bool)
+	sharedConfigMaps := make(map[string]bool)
+	for i := range sharedResources.Items {
+		sharedResource := &sharedResources.Items[i]
+
+		selected, err := isSandboxSelected(sandbox, *sharedResource)
+		if err != nil {
+			r.setSourceReady(ctx, sharedResource, corev1.ConditionFalse, "InvalidSelector", err.Error())
+			continue
+		}
+
+		if !selected {
+			continue
+		}
+
+		switch sharedResource.Spec.Kind {
+		case "Secret":
+			var secret corev1.Secret
+			secret, err = r.reconcileSharedSecret(ctx, sandbox, *sharedResource)
+			if err == nil {
+				sharedSecrets[secret.Name] = true
+			}
+		case "ConfigMap":
+			var configMap corev1.ConfigMap
+			configMap, err = r.reconcileSharedConfigMap(ctx, sandbox, *sharedResource)
+			if err == nil {
+				sharedConfigMaps[configMap.Name] = true
+			}
+		default:
+			r.setSourceReady(ctx, sharedResource, corev1.ConditionFalse, "UnsupportedKind", fmt.Sprintf("Kind %q is not supported, use Secret or ConfigMap", sharedResource.Spec.Kind))
+			continue
+		}
+
+		var conflict *sharedResourceConflictError
+		switch {
+		case err == nil:
+			r.setSourceReady(ctx, sharedResource, corev1.ConditionTrue, "SourceFound", "")
+		case isWrappedNotFound(err):
+			r.setSourceReady(ctx, sharedResource, corev1.ConditionFalse, "SourceNotFound", fmt.Sprintf("%s %s/%s was not found", sharedResource.Spec.Kind, sharedResource.Spec.SourceNamespace, sharedResource.Spec.SourceName))
+		case goerrors.As(err, &conflict):
+			r.recorder.Event(sharedResource, corev1.EventTypeWarning, "TargetConflict", conflict.Error())
+		default:
+			return fmt.Errorf("reconcile shared %s %s: %w", sharedResource.Spec.Kind, sharedResource.Name, err)
+		}
+	}
+
+	namespace := getNamespace(sandbox)
+	listOptions := []client.ListOption{
+		client.InNamespace(namespace.Name),
+		client.MatchingLabels{sharedResourceLabel: "true"},
+	}
+
+	var secrets corev1.SecretList
+	if err := r.client.List(ctx, &secrets, listOptions...); err != nil {
+		return fmt.Errorf("list shared Secrets: %w", err)
+	}
+
+	for i := range secrets.Items {
+		if sharedSecrets[secrets.Items[i].Name] {
+			continue
+		}
+
+		if err := r.client.Delete(ctx, &secrets.Items[i]); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("delete shared Secret: %w", err)
+		}
+	}
+
+	var configMaps corev1.ConfigMapList
+	if err := r.client.List(ctx, &configMaps, listOptions...); err != nil {
+		return fmt.Errorf("list shared ConfigMaps: %w", err)
+	}
+
+	for i := range configMaps.Items {
+		if sharedConfigMaps[configMaps.Items[i].Name] {
+			continue
+		}
+
+		if err := r.client.Delete(ctx, &configMaps.Items[i]); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("delete shared ConfigMap: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (r *ReconcileSandbox) reconcileSharedSecret(ctx context.Context, sandbox operatorsv1alpha1.Sandbox, sharedResource operatorsv1alpha1.SandboxSharedResource) (corev1.Secret, error) {
+	var source corev1.Secret
+	if err := r.getSourceReader(sharedResource.Spec.SourceNamespace).Get(ctx, types.NamespacedName{Name: sharedResource.Spec.SourceName, Namespace: sharedResource.Spec.SourceNamespace}, &source); err != nil {
+		return corev1.Secret{}, fmt.Errorf("get source Secret: %w", err)
+	}
+
+	secret := corev1.Secret{
+		ObjectMeta: getSharedResourceObjectMeta(sandbox, sharedResource),
+		Type:       source.Type,
+	}
+
+	var existing corev1.Secret
+	if err := r.checkSharedTarget(ctx, sandbox, &existing, secret.ObjectMeta); err != nil {
+		return corev1.Secret{}, err
+	}
+
+	_, err := ctrl.CreateOrUpdate(ctx, r.client, &secret, func() error {
+		secret.Labels = getSharedResourceLabels()
+		secret.Data = source.Data
+		return controllerutil.SetControllerReference(&sandbox, &secret, r.scheme)
+	})
+	if err != nil {
+		return corev1.Secret{}, fmt.Errorf("create or update Secret: %w", err)
+	}
+
+	return secret, nil
+}
+
+func (r *ReconcileSandbox) reconcileSharedConfigMap(ctx context.Context, sandbox operatorsv1alpha1.Sandbox, sharedResource operatorsv1alpha1.SandboxSharedResource) (corev1.ConfigMap, error) {
+	var source corev1.ConfigMap
+	if err := r.getSourceReader(sharedResource.Spec.SourceNamespace).Get(ctx, types.NamespacedName{Name: sharedResource.Spec.SourceName, Namespace: sharedResource.Spec.SourceNamespace}, &source); err != nil {
+		return corev1.ConfigMap{}, fmt.Errorf("get source ConfigMap: %w", err)
+	}
+
+	configMap := corev1.ConfigMap{
+		ObjectMeta: getSharedResourceObjectMeta(sandbox, sharedResource),
+	}
+
+	var existing corev1.ConfigMap
+	if err := r.checkSharedTarget(ctx, sandbox, &existing, configMap.ObjectMeta); err != nil {
+		return corev1.ConfigMap{}, err
+	}
+
+	_, err := ctrl.CreateOrUpdate(ctx, r.client, &configMap, func() error {
+		configMap.Labels = getSharedResourceLabels()
+		configMap.Data = source.Data
+		configMap.BinaryData = source.BinaryData
+		return controllerutil.SetControllerReference(&sandbox, &configMap, r.scheme)
+	})
+	if err != nil {
+		return corev1.ConfigMap{}, fmt.Errorf("create or update ConfigMap: %w", err)
+	}
+
+	return configMap, nil
+}
+
+// sharedResourceConflictError is returned when the target of a copy already
+// exists in the sandbox and is not managed by the operator
+type sharedResourceConflictError struct {
+	kind      string
+	name      string
+	namespace string
+}
+
+func (e *sharedResourceConflictError) Error() string {
+	return fmt.Sprintf("%s %s/%s already exists and is not managed by the operator, so it was not overwritten", e.kind, e.namespace, e.name)
+}
+
+// checkSharedTarget returns a sharedResourceConflictError when the target
+// exists but was not copied by the operator, so that objects created by the
+// owners of the sandbox are never overwritten
+func (r *ReconcileSandbox) checkSharedTarget(ctx context.Context, sandbox operatorsv1alpha1.Sandbox, existing runtime.Object, target metav1.ObjectMeta) error {
+	if err := r.client.Get(ctx, types.NamespacedName{Name: target.Name, Namespace: target.Namespace}, existing); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+
+		return fmt.Errorf("get target: %w", err)
+	}
+
+	object, err := meta.Accessor(existing)
+	if err != nil {
+		return fmt.Errorf("get target metadata: %w", err)
+	}
+
+	owner := metav1.GetControllerOf(object)
+	if object.GetLabels()[sharedResourceLabel] == "true" && owner != nil && owner.Kind == "Sandbox" && owner.Name == sandbox.Name {
+		return nil
+	}
+
+	kind := "Secret"
+	if _, ok := existing.(*corev1.ConfigMap); ok {
+		kind = "ConfigMap"
+	}
+
+	return &sharedResourceConflictError{kind: kind, name: target.Name, namespace: target.Namespace}
+}
+
+// isWrappedNotFound reports whether a NotFound API error is wrapped in err,
+// which errors.IsNotFound does not unwrap
+func isWrappedNotFound(err error) bool {
+	var status errors.APIStatus
+	return goerrors.As(err, &status) && status.Status().Reason == metav1.StatusReasonNotFound
+}
+
+// setSourceReady sets the SourceReady condition of the SandboxSharedResource,
+// and records an Event when the source cannot be copied
+func (r *ReconcileSandbox) setSourceReady(ctx context.Context, sharedResource *operatorsv1alpha1.SandboxSharedResource, status corev1.ConditionStatus, reason string, message string) {
+	if !setConditionIn(&sharedResource.Status.Conditions, operatorsv1alpha1.SandboxConditionSourceReady, status, reason, message) {
+		return
+	}
+
+	if status != corev1.ConditionTrue {
+		r.recorder.Event(sharedResource, corev1.EventTypeWarning, reason, message)
+	}
+
+	if err := r.client.Status().Update(ctx, sharedResource); err != nil {
+		getLogger(ctx).Error(err, "Update SandboxSharedResource status", "sharedResource", sharedResource.Name)
+	}
+}
+
+func getSharedResourceObjectMeta(sandbox operatorsv1alpha1.Sandbox, sharedResource operatorsv1alpha1.SandboxSharedResource) metav1.ObjectMeta {
+	targetName := sharedResource.Spec.TargetName
+	if targetName == "" {
+		targetName = sharedResource.Spec.SourceName
+	}
+
+	objectMeta := metav1.ObjectMeta{
+		Name:      targetName,
+		Namespace: "sandbox-" + sandbox.Name,
+		Labels:    getSharedResourceLabels(),
+	}
+
+	return objectMeta
+}
+
+func getSharedResourceLabels() map[string]string {
+	sharedResourceLabels := getCommonLabels()
+	sharedResourceLabels[sharedResourceLabel] = "true"
+
+	return sharedResourceLabels
+}
+
+func isSandboxSelected(sandbox operatorsv1alpha1.Sandbox, sharedResource operatorsv1alpha1.SandboxSharedResource) (bool, error) {
+	if sharedResource.Spec.SandboxSelector == nil {
+		return true, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(sharedResource.Spec.SandboxSelector)
+	if err != nil {
+		return false, fmt.Errorf("parse sandbox selector: %w", err)
+	}
+
+	return selector.Matches(labels.Set(sandbox.Labels)), nil
+}
+
+// sourceCaches caches the Secrets and ConfigMaps of the namespaces that
+// SandboxSharedResources copy from, so that the sources are watched and read
+// without caching every Secret and ConfigMap in the cluster. A cache is started
+// for each source namespace, and stopped once no SandboxSharedResource copies
+// from it anymore.
+type sourceCaches struct {
+	newCache func(namespace string) (cache.Cache, error)
+
+	// changed is sent an event when a source is created or updated, so that
+	// every Sandbox is reconciled with the new source
+	changed chan<- event.GenericEvent
+
+	mu      sync.Mutex
+	stop    <-chan struct{}
+	sources map[string]bool
+	caches  map[string]*namespaceCache
+}
+
+// namespaceCache is the cache of a single source namespace, which is only
+// read from once it has synced
+type namespaceCache struct {
+	cache.Cache
+	stop   chan struct{}
+	synced int32
+}
+
+func newSourceCaches(newCache func(namespace string) (cache.Cache, error), changed chan<- event.GenericEvent) *sourceCaches {
+	return &sourceCaches{
+		newCache: newCache,
+		changed:  changed,
+		sources:  make(map[string]bool),
+		caches:   make(map[string]*namespaceCache),
+	}
+}
+
+// Start starts the caches of the source namespaces and stops them all once
+// it is stopped
+func (s *sourceCaches) Start(stop <-chan struct{}) error {
+	s.mu.Lock()
+	s.stop = stop
+	s.syncCaches()
+	s.mu.Unlock()
+
+	<-stop
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for namespace, namespaceCache := range s.caches {
+		close(namespaceCache.stop)
+		delete(s.caches, namespace)
+	}
+
+	return nil
+}
+
+// setSources sets the sources of the SandboxSharedResources, starting the
+// caches of new source namespaces and stopping the ones no longer used
+func (s *sourceCaches) setSources(sharedResources []operatorsv1alpha1.SandboxSharedResource) {
+	sources := make(map[string]bool)
+	for _, sharedResource := range sharedResources {
+		sources[getSourceKey(sharedResource.Spec.Kind, sharedResource.Spec.SourceNamespace, sharedResource.Spec.SourceName)] = true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sources = sources
+	if s.stop != nil {
+		s.syncCaches()
+	}
+}
+
+// syncCaches starts and stops the namespace caches to match the sources.
+// It must be called with the lock held.
+func (s *sourceCaches) syncCaches() {
+	namespaces := make(map[string]bool)
+	for key := range s.sources {
+		namespaces[strings.SplitN(key, "/", 3)[1]] = true
+	}
+
+	for namespace, namespaceCache := range s.caches {
+		if !namespaces[namespace] {
+			close(namespaceCache.stop)
+			delete(s.caches, namespace)
+		}
+	}
+
+	for namespace := range namespaces {
+		if _, ok := s.caches[namespace]; ok {
+			continue
+		}
+
+		namespaceCache, err := s.startCache(namespace)
+		if err != nil {
+			log.Error(err, "Start source cache", "namespace", namespace)
+			continue
+		}
+
+		s.caches[namespace] = namespaceCache
+	}
+}
+
+func (s *sourceCaches) startCache(namespace string) (*namespaceCache, error) {
+	sourceCache, err := s.newCache(namespace)
+	if err != nil {
+		return nil, fmt.Errorf("new cache: %w", err)
+	}
+
+	sourceTypes := map[string]runtime.Object{
+		"Secret":    &corev1.Secret{},
+		"ConfigMap": &corev1.ConfigMap{},
+	}
+
+	for kind, sourceType := range sourceTypes {
+		informer, err := sourceCache.GetInformer(sourceType)
+		if err != nil {
+			return nil, fmt.Errorf("get %s informer: %w", kind, err)
+		}
+
+		informer.AddEventHandler(s.getEventHandler(kind))
+	}
+
+	namespaceCache := &namespaceCache{
+		Cache: sourceCache,
+		stop:  make(chan struct{}),
+	}
+
+	go func() {
+		if err := sourceCache.Start(namespaceCache.stop); err != nil {
+			log.Error(err, "Run source cache", "namespace", namespace)
+		}
+	}()
+
+	go func() {
+		if sourceCache.WaitForCacheSync(namespaceCache.stop) {
+			atomic.StoreInt32(&namespaceCache.synced, 1)
+		}
+	}()
+
+	return namespaceCache, nil
+}
+
+// getEventHandler sends a changed event when a source of the given kind is
+// created or updated
+func (s *sourceCaches) getEventHandler(kind string) toolscache.ResourceEventHandler {
+	sendChanged := func(obj interface{}) {
+		object, err := meta.Accessor(obj)
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		isSource := s.sources[getSourceKey(kind, object.GetNamespace(), object.GetName())]
+		s.mu.Unlock()
+
+		if !isSource {
+			return
+		}
+
+		// An event that is already waiting will reconcile every Sandbox, so
+		// there is no need to wait to send another one
+		select {
+		case s.changed <- event.GenericEvent{Meta: object}:
+		default:
+		}
+	}
+
+	handler := toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    sendChanged,
+		UpdateFunc: func(_, obj interface{}) { sendChanged(obj) },
+	}
+
+	return handler
+}
+
+// getReader returns the cache of the source namespace, or nil when the
+// namespace has no cache or it has not synced yet
+func (s *sourceCaches) getReader(namespace string) client.Reader {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	namespaceCache, ok := s.caches[namespace]
+	if !ok || atomic.LoadInt32(&namespaceCache.synced) == 0 {
+		return nil
+	}
+
+	return namespaceCache
+}
+
+// getSourceReader returns the reader of the sources in the namespace. The API
+// server is read until the cache of the namespace has synced.
+func (r *ReconcileSandbox) getSourceReader(namespace string) client.Reader {
+	if r.sourceCaches == nil {
+		return r.client
+	}
+
+	if reader := r.sourceCaches.getReader(namespace); reader != nil {
+		return reader
+	}
+
+	return r.apiReader
+}
+
+func getSourceKey(kind string, namespace string, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
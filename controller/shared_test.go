@@ -0,0 +1,232 @@
+// +build !integration
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/plexsystems/sandbox-operator/apis"
+	operatorsv1alpha1 "github.com/plexsystems/sandbox-operator/apis/operators/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestSandboxController_SharedResource_CopiesAndRemovesConfigMap(t *testing.T) {
+	ctx := context.TODO()
+
+	s := scheme.Scheme
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatalf("add scheme: %v", err)
+	}
+
+	fakeClient := fake.NewFakeClientWithScheme(s)
+	r := NewReconcileSandbox(fakeClient, fakeClient, s, DefaultSubjects{}, &record.FakeRecorder{})
+
+	sandbox := operatorsv1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "test",
+			Labels: map[string]string{"team": "platform"},
+		},
+	}
+
+	if err := r.client.Create(ctx, &sandbox); err != nil {
+		t.Fatalf("create sandbox: %v", err)
+	}
+
+	sourceConfigMap := corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "proxy",
+			Namespace: "infra",
+		},
+		Data: map[string]string{"HTTP_PROXY": "http://proxy:3128"},
+	}
+
+	if err := r.client.Create(ctx, &sourceConfigMap); err != nil {
+		t.Fatalf("create source configmap: %v", err)
+	}
+
+	sharedResource := operatorsv1alpha1.SandboxSharedResource{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "proxy",
+		},
+		Spec: operatorsv1alpha1.SandboxSharedResourceSpec{
+			Kind:            "ConfigMap",
+			SourceName:      "proxy",
+			SourceNamespace: "infra",
+			TargetName:      "proxy-settings",
+			SandboxSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"team": "platform"},
+			},
+		},
+	}
+
+	if err := r.client.Create(ctx, &sharedResource); err != nil {
+		t.Fatalf("create shared resource: %v", err)
+	}
+
+	request := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name: sandbox.Name,
+		},
+	}
+
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("reconcile sandbox: %v", err)
+	}
+
+	var copiedConfigMap corev1.ConfigMap
+	if err := r.client.Get(ctx, types.NamespacedName{Name: "proxy-settings", Namespace: "sandbox-test"}, &copiedConfigMap); err != nil {
+		t.Fatalf("expected ConfigMap to be copied but it was not: %v", err)
+	}
+
+	if copiedConfigMap.Data["HTTP_PROXY"] != "http://proxy:3128" {
+		t.Errorf("expected ConfigMap data to be copied but it was not: %v", copiedConfigMap.Data)
+	}
+
+	sandbox.Labels = map[string]string{"team": "other"}
+	if err := r.client.Update(ctx, &sandbox); err != nil {
+		t.Fatalf("update sandbox: %v", err)
+	}
+
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("reconcile sandbox: %v", err)
+	}
+
+	err := r.client.Get(ctx, types.NamespacedName{Name: "proxy-settings", Namespace: "sandbox-test"}, &corev1.ConfigMap{})
+	if !errors.IsNotFound(err) {
+		t.Errorf("expected ConfigMap to be removed but it was not: %v", err)
+	}
+}
+
+func TestSandboxController_SharedResourceProblems_DoNotFailSandbox(t *testing.T) {
+	ctx := context.TODO()
+
+	s := scheme.Scheme
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatalf("add scheme: %v", err)
+	}
+
+	sandbox := operatorsv1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+	}
+
+	sourceConfigMap := corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "proxy",
+			Namespace: "infra",
+		},
+		Data: map[string]string{"HTTP_PROXY": "http://proxy:3128"},
+	}
+
+	userConfigMap := corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "proxy",
+			Namespace: "sandbox-test",
+		},
+		Data: map[string]string{"HTTP_PROXY": "http://mine:3128"},
+	}
+
+	missingSource := operatorsv1alpha1.SandboxSharedResource{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "missing",
+		},
+		Spec: operatorsv1alpha1.SandboxSharedResourceSpec{
+			Kind:            "Secret",
+			SourceName:      "missing",
+			SourceNamespace: "infra",
+		},
+	}
+
+	conflicting := operatorsv1alpha1.SandboxSharedResource{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "proxy",
+		},
+		Spec: operatorsv1alpha1.SandboxSharedResourceSpec{
+			Kind:            "ConfigMap",
+			SourceName:      "proxy",
+			SourceNamespace: "infra",
+		},
+	}
+
+	fakeClient := fake.NewFakeClientWithScheme(s, &sandbox, &sourceConfigMap, &userConfigMap, &missingSource, &conflicting)
+	r := NewReconcileSandbox(fakeClient, fakeClient, s, DefaultSubjects{}, &record.FakeRecorder{})
+
+	request := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name: sandbox.Name,
+		},
+	}
+
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("expected the sandbox to reconcile despite the shared resources but got: %v", err)
+	}
+
+	var foundConfigMap corev1.ConfigMap
+	if err := r.client.Get(ctx, types.NamespacedName{Name: "proxy", Namespace: "sandbox-test"}, &foundConfigMap); err != nil {
+		t.Fatalf("get configmap: %v", err)
+	}
+
+	if foundConfigMap.Data["HTTP_PROXY"] != "http://mine:3128" {
+		t.Errorf("expected the ConfigMap of the user to be kept but found: %v", foundConfigMap.Data)
+	}
+
+	var foundSharedResource operatorsv1alpha1.SandboxSharedResource
+	if err := r.client.Get(ctx, types.NamespacedName{Name: "missing"}, &foundSharedResource); err != nil {
+		t.Fatalf("get shared resource: %v", err)
+	}
+
+	conditions := foundSharedResource.Status.Conditions
+	if len(conditions) != 1 || conditions[0].Status != corev1.ConditionFalse || conditions[0].Reason != "SourceNotFound" {
+		t.Errorf("expected the SourceReady condition to report the missing source but found: %v", conditions)
+	}
+}
+
+func TestSourceCaches_SourceChanged_SendsEvent(t *testing.T) {
+	changed := make(chan event.GenericEvent, 1)
+	sources := newSourceCaches(nil, changed)
+	sources.setSources([]operatorsv1alpha1.SandboxSharedResource{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "ca"},
+			Spec: operatorsv1alpha1.SandboxSharedResourceSpec{
+				Kind:            "Secret",
+				SourceName:      "ca",
+				SourceNamespace: "shared",
+			},
+		},
+	})
+
+	otherSecret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "shared"}}
+	sources.getEventHandler("Secret").OnAdd(otherSecret)
+
+	configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "ca", Namespace: "shared"}}
+	sources.getEventHandler("ConfigMap").OnAdd(configMap)
+
+	select {
+	case e := <-changed:
+		t.Fatalf("expected no event for objects that are not sources but got one for %s", e.Meta.GetName())
+	default:
+	}
+
+	sourceSecret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "ca", Namespace: "shared"}}
+	sources.getEventHandler("Secret").OnUpdate(sourceSecret, sourceSecret)
+
+	select {
+	case e := <-changed:
+		if e.Meta.GetName() != "ca" {
+			t.Errorf("expected an event for the source but got one for %s", e.Meta.GetName())
+		}
+	default:
+		t.Error("expected an event when the source was updated")
+	}
+}
@@ -0,0 +1,305 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	operatorsv1alpha1 "github.com/plexsystems/sandbox-operator/apis/operators/v1alpha1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// cleanupFinalizer blocks deletion of a Sandbox until its teardown has completed
+const cleanupFinalizer = "operators.plex.dev/cleanup"
+
+// teardownStep is a single step of the Sandbox teardown. Steps are run in order
+// on every reconcile and must be safe to run more than once. A step that is not
+// done yet stops the teardown until the next reconcile.
+//
+// Steps read through the apiReader so that deleting a Sandbox does not start
+// cluster-wide informers for workloads, and so that namespace termination is
+// observed without waiting on the cache.
+type teardownStep struct {
+	name string
+	run  func(ctx context.Context, sandbox *operatorsv1alpha1.Sandbox) (bool, error)
+}
+
+func (r *ReconcileSandbox) getTeardownSteps() []teardownStep {
+	teardownSteps := []teardownStep{
+		{name: "ScaleDown", run: r.scaleDown},
+		{name: "DeleteNamespace", run: r.deleteNamespace},
+		{name: "WaitForNamespace", run: r.waitForNamespace},
+		{name: "DeleteClusterRBAC", run: r.deleteClusterRBAC},
+		{name: "Notify", run: r.notifyDeleted},
+	}
+
+	return teardownSteps
+}
+
+func (r *ReconcileSandbox) handleTeardown(ctx context.Context, sandbox operatorsv1alpha1.Sandbox) (reconcile.Result, error) {
+	const requeueTime = 5 * time.Second
+
+	if !ContainsString(sandbox.Finalizers, cleanupFinalizer) {
+		return reconcile.Result{}, nil
+	}
+
+	if sandbox.Status.Phase != operatorsv1alpha1.SandboxPhaseTerminating {
+		r.recorder.Event(&sandbox, corev1.EventTypeNormal, "Terminating", "Tearing down sandbox")
+	}
+
+	for _, step := range r.getTeardownSteps() {
+		start := time.Now()
+		done, err := step.run(ctx, &sandbox)
+		observeStep(step.name, start, err)
+		logger := getLogger(ctx).WithValues("step", step.name, "duration", time.Since(start).String())
+		if err != nil {
+			r.recorder.Eventf(&sandbox, corev1.EventTypeWarning, "TeardownFailed", "Failed to run teardown step %s: %v", step.name, err)
+			return reconcile.Result{}, fmt.Errorf("teardown %s: %w", step.name, err)
+		}
+
+		if done {
+			logger.V(1).Info("Teardown step done")
+			continue
+		}
+
+		logger.Info("Waiting on teardown step")
+
+		sandbox.Status.Phase = operatorsv1alpha1.SandboxPhaseTerminating
+		sandbox.Status.TeardownStep = step.name
+		if err := r.client.Status().Update(ctx, &sandbox); err != nil {
+			return reconcile.Result{}, fmt.Errorf("update status: %w", err)
+		}
+
+		return reconcile.Result{RequeueAfter: requeueTime}, nil
+	}
+
+	sandbox.Finalizers = removeString(sandbox.Finalizers, cleanupFinalizer)
+	if err := r.client.Update(ctx, &sandbox); err != nil {
+		return reconcile.Result{}, fmt.Errorf("remove finalizer: %w", err)
+	}
+
+	// The deletion is only audited once the finalizer is removed, as the
+	// teardown runs again when removing it fails
+	r.audit(ctx, sandbox, AuditDeleted, sandbox.Status.DeletedBy, nil, nil)
+
+	r.forget(sandbox.Name)
+
+	return reconcile.Result{}, nil
+}
+
+func (r *ReconcileSandbox) scaleDown(ctx context.Context, sandbox *operatorsv1alpha1.Sandbox) (bool, error) {
+	namespace := getNamespace(*sandbox)
+
+	var deployments appsv1.DeploymentList
+	if err := r.apiReader.List(ctx, &deployments, client.InNamespace(namespace.Name)); err != nil {
+		return false, fmt.Errorf("list deployments: %w", err)
+	}
+
+	for i := range deployments.Items {
+		deployment := &deployments.Items[i]
+		if deployment.Spec.Replicas != nil && *deployment.Spec.Replicas == 0 {
+			continue
+		}
+
+		patch := client.MergeFrom(deployment.DeepCopy())
+		deployment.Spec.Replicas = new(int32)
+		if err := r.client.Patch(ctx, deployment, patch); err != nil && !errors.IsNotFound(err) {
+			return false, fmt.Errorf("scale down deployment %s: %w", deployment.Name, err)
+		}
+	}
+
+	var statefulSets appsv1.StatefulSetList
+	if err := r.apiReader.List(ctx, &statefulSets, client.InNamespace(namespace.Name)); err != nil {
+		return false, fmt.Errorf("list statefulsets: %w", err)
+	}
+
+	for i := range statefulSets.Items {
+		statefulSet := &statefulSets.Items[i]
+		if statefulSet.Spec.Replicas != nil && *statefulSet.Spec.Replicas == 0 {
+			continue
+		}
+
+		patch := client.MergeFrom(statefulSet.DeepCopy())
+		statefulSet.Spec.Replicas = new(int32)
+		if err := r.client.Patch(ctx, statefulSet, patch); err != nil && !errors.IsNotFound(err) {
+			return false, fmt.Errorf("scale down statefulset %s: %w", statefulSet.Name, err)
+		}
+	}
+
+	// ReplicaSets of Deployments are scaled down by their Deployment, so only
+	// the ones created on their own are scaled down here
+	var replicaSets appsv1.ReplicaSetList
+	if err := r.apiReader.List(ctx, &replicaSets, client.InNamespace(namespace.Name)); err != nil {
+		return false, fmt.Errorf("list replicasets: %w", err)
+	}
+
+	for i := range replicaSets.Items {
+		replicaSet := &replicaSets.Items[i]
+		if replicaSet.Spec.Replicas != nil && *replicaSet.Spec.Replicas == 0 {
+			continue
+		}
+
+		owner := metav1.GetControllerOf(replicaSet)
+		if owner != nil && owner.Kind == "Deployment" {
+			continue
+		}
+
+		patch := client.MergeFrom(replicaSet.DeepCopy())
+		replicaSet.Spec.Replicas = new(int32)
+		if err := r.client.Patch(ctx, replicaSet, patch); err != nil && !errors.IsNotFound(err) {
+			return false, fmt.Errorf("scale down replicaset %s: %w", replicaSet.Name, err)
+		}
+	}
+
+	// Workloads are given the chance to shut down gracefully before their
+	// namespace is deleted, unless that takes longer than the timeout
+	var pods corev1.PodList
+	if err := r.apiReader.List(ctx, &pods, client.InNamespace(namespace.Name)); err != nil {
+		return false, fmt.Errorf("list pods: %w", err)
+	}
+
+	var scalingDown int
+	for _, pod := range pods.Items {
+		owner := metav1.GetControllerOf(&pod)
+		if owner != nil && (owner.Kind == "ReplicaSet" || owner.Kind == "StatefulSet") {
+			scalingDown++
+		}
+	}
+
+	if scalingDown == 0 {
+		return true, nil
+	}
+
+	scaleDownTimeout := r.getOptions().ScaleDownTimeout
+	if sandbox.DeletionTimestamp != nil && time.Since(sandbox.DeletionTimestamp.Time) > scaleDownTimeout {
+		getLogger(ctx).Info("Pods did not stop in time, deleting the namespace", "pods", scalingDown, "timeout", scaleDownTimeout.String())
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (r *ReconcileSandbox) deleteNamespace(ctx context.Context, sandbox *operatorsv1alpha1.Sandbox) (bool, error) {
+	var namespace corev1.Namespace
+	if err := r.client.Get(ctx, types.NamespacedName{Name: getNamespace(*sandbox).Name}, &namespace); err != nil {
+		if errors.IsNotFound(err) {
+			return true, nil
+		}
+
+		return false, fmt.Errorf("get namespace: %w", err)
+	}
+
+	if namespace.DeletionTimestamp != nil {
+		return true, nil
+	}
+
+	if err := r.client.Delete(ctx, &namespace, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+		return false, fmt.Errorf("delete namespace: %w", err)
+	}
+
+	return true, nil
+}
+
+func (r *ReconcileSandbox) waitForNamespace(ctx context.Context, sandbox *operatorsv1alpha1.Sandbox) (bool, error) {
+	stuckTime := r.getOptions().NamespaceStuckTimeout
+
+	var namespace corev1.Namespace
+	if err := r.apiReader.Get(ctx, types.NamespacedName{Name: getNamespace(*sandbox).Name}, &namespace); err != nil {
+		if errors.IsNotFound(err) {
+			sandbox.Status.StuckResources = nil
+			return true, nil
+		}
+
+		return false, fmt.Errorf("get namespace: %w", err)
+	}
+
+	if namespace.DeletionTimestamp == nil || time.Since(namespace.DeletionTimestamp.Time) < stuckTime {
+		return false, nil
+	}
+
+	stuckResources, err := r.getRemainingResources(ctx, namespace.Name)
+	if err != nil {
+		return false, fmt.Errorf("get remaining resources: %w", err)
+	}
+
+	sandbox.Status.StuckResources = stuckResources
+	return false, nil
+}
+
+// getRemainingResources returns the names of the resources that commonly block
+// a namespace from terminating, such as pods and volume claims with finalizers
+func (r *ReconcileSandbox) getRemainingResources(ctx context.Context, namespace string) ([]string, error) {
+	var remainingResources []string
+
+	var pods corev1.PodList
+	if err := r.apiReader.List(ctx, &pods, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("list pods: %w", err)
+	}
+
+	for _, pod := range pods.Items {
+		remainingResources = append(remainingResources, "Pod/"+pod.Name)
+	}
+
+	var persistentVolumeClaims corev1.PersistentVolumeClaimList
+	if err := r.apiReader.List(ctx, &persistentVolumeClaims, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("list persistent volume claims: %w", err)
+	}
+
+	for _, persistentVolumeClaim := range persistentVolumeClaims.Items {
+		remainingResources = append(remainingResources, "PersistentVolumeClaim/"+persistentVolumeClaim.Name)
+	}
+
+	var services corev1.ServiceList
+	if err := r.apiReader.List(ctx, &services, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("list services: %w", err)
+	}
+
+	for _, service := range services.Items {
+		remainingResources = append(remainingResources, "Service/"+service.Name)
+	}
+
+	return remainingResources, nil
+}
+
+func (r *ReconcileSandbox) deleteClusterRBAC(ctx context.Context, sandbox *operatorsv1alpha1.Sandbox) (bool, error) {
+	clusterRoleBinding := getClusterRoleBinding(*sandbox)
+	if err := r.client.Delete(ctx, &clusterRoleBinding); err != nil && !errors.IsNotFound(err) {
+		return false, fmt.Errorf("delete ClusterRoleBinding: %w", err)
+	}
+
+	clusterRole := getClusterRole(*sandbox)
+	if err := r.client.Delete(ctx, &clusterRole); err != nil && !errors.IsNotFound(err) {
+		return false, fmt.Errorf("delete ClusterRole: %w", err)
+	}
+
+	return true, nil
+}
+
+func (r *ReconcileSandbox) notifyDeleted(ctx context.Context, sandbox *operatorsv1alpha1.Sandbox) (bool, error) {
+	for _, secretName := range r.getOptions().PullSecretNames {
+		pullSecretSynced.DeleteLabelValues(sandbox.Name, secretName)
+	}
+
+	getLogger(ctx).Info("Sandbox was torn down")
+	r.notify(ctx, *sandbox, NotificationDeleted, fmt.Sprintf("Deleted namespace %s", getNamespace(*sandbox).Name))
+	r.recorder.Eventf(sandbox, corev1.EventTypeNormal, "Deleted", "Deleted namespace %s and cluster RBAC", getNamespace(*sandbox).Name)
+	return true, nil
+}
+
+func removeString(values []string, value string) []string {
+	var result []string
+	for _, v := range values {
+		if v != value {
+			result = append(result, v)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,179 @@
+// +build !integration
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/plexsystems/sandbox-operator/apis"
+	operatorsv1alpha1 "github.com/plexsystems/sandbox-operator/apis/operators/v1alpha1"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestSandboxController_Deleted_RunsTeardownAndRemovesFinalizer(t *testing.T) {
+	ctx := context.TODO()
+
+	s := scheme.Scheme
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatalf("add scheme: %v", err)
+	}
+
+	now := metav1.Now()
+	sandbox := operatorsv1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "test",
+			DeletionTimestamp: &now,
+			Finalizers:        []string{cleanupFinalizer},
+		},
+	}
+
+	replicas := int32(3)
+	deployment := appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "app",
+			Namespace: "sandbox-test",
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+		},
+	}
+
+	namespace := getNamespace(sandbox)
+	clusterRole := getClusterRole(sandbox)
+	clusterRoleBinding := getClusterRoleBinding(sandbox)
+
+	fakeClient := fake.NewFakeClientWithScheme(s, &sandbox, &deployment, &namespace, &clusterRole, &clusterRoleBinding)
+	r := NewReconcileSandbox(fakeClient, fakeClient, s, DefaultSubjects{}, &record.FakeRecorder{})
+
+	request := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name: sandbox.Name,
+		},
+	}
+
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("reconcile sandbox: %v", err)
+	}
+
+	var foundDeployment appsv1.Deployment
+	if err := r.client.Get(ctx, types.NamespacedName{Name: "app", Namespace: "sandbox-test"}, &foundDeployment); err != nil {
+		t.Fatalf("get deployment: %v", err)
+	}
+
+	if *foundDeployment.Spec.Replicas != 0 {
+		t.Errorf("expected Deployment to be scaled down but it has %d replicas", *foundDeployment.Spec.Replicas)
+	}
+
+	if err := r.client.Get(ctx, types.NamespacedName{Name: namespace.Name}, &corev1.Namespace{}); !errors.IsNotFound(err) {
+		t.Errorf("expected Namespace to be deleted but it was not: %v", err)
+	}
+
+	if err := r.client.Get(ctx, types.NamespacedName{Name: clusterRole.Name}, &rbacv1.ClusterRole{}); !errors.IsNotFound(err) {
+		t.Errorf("expected ClusterRole to be deleted but it was not: %v", err)
+	}
+
+	if err := r.client.Get(ctx, types.NamespacedName{Name: clusterRoleBinding.Name}, &rbacv1.ClusterRoleBinding{}); !errors.IsNotFound(err) {
+		t.Errorf("expected ClusterRoleBinding to be deleted but it was not: %v", err)
+	}
+
+	var foundSandbox operatorsv1alpha1.Sandbox
+	if err := r.client.Get(ctx, request.NamespacedName, &foundSandbox); err != nil {
+		t.Fatalf("get sandbox: %v", err)
+	}
+
+	if ContainsString(foundSandbox.Finalizers, cleanupFinalizer) {
+		t.Errorf("expected cleanup finalizer to be removed but it was not: %v", foundSandbox.Finalizers)
+	}
+}
+
+func TestSandboxController_Deleted_WaitsForPodsToStop(t *testing.T) {
+	ctx := context.TODO()
+
+	s := scheme.Scheme
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatalf("add scheme: %v", err)
+	}
+
+	now := metav1.Now()
+	sandbox := operatorsv1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "test",
+			DeletionTimestamp: &now,
+			Finalizers:        []string{cleanupFinalizer},
+		},
+	}
+
+	controller := true
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "app-1",
+			Namespace: "sandbox-test",
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "app", Controller: &controller},
+			},
+		},
+	}
+
+	replicas := int32(2)
+	replicaSet := appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "app",
+			Namespace: "sandbox-test",
+		},
+		Spec: appsv1.ReplicaSetSpec{
+			Replicas: &replicas,
+		},
+	}
+
+	namespace := getNamespace(sandbox)
+
+	fakeClient := fake.NewFakeClientWithScheme(s, &sandbox, &pod, &replicaSet, &namespace)
+	r := NewReconcileSandbox(fakeClient, fakeClient, s, DefaultSubjects{}, &record.FakeRecorder{})
+
+	request := reconcile.Request{
+		NamespacedName: types.NamespacedName{
+			Name: sandbox.Name,
+		},
+	}
+
+	result, err := r.Reconcile(request)
+	if err != nil {
+		t.Fatalf("reconcile sandbox: %v", err)
+	}
+
+	if result.RequeueAfter == 0 {
+		t.Errorf("expected the teardown to be requeued while pods are stopping")
+	}
+
+	if err := r.client.Get(ctx, types.NamespacedName{Name: namespace.Name}, &corev1.Namespace{}); err != nil {
+		t.Errorf("expected the Namespace to be kept while pods are stopping but got: %v", err)
+	}
+
+	var foundSandbox operatorsv1alpha1.Sandbox
+	if err := r.client.Get(ctx, request.NamespacedName, &foundSandbox); err != nil {
+		t.Fatalf("get sandbox: %v", err)
+	}
+
+	if foundSandbox.Status.TeardownStep != "ScaleDown" {
+		t.Errorf("expected the teardown to wait on ScaleDown but it was on %q", foundSandbox.Status.TeardownStep)
+	}
+	var foundReplicaSet appsv1.ReplicaSet
+	if err := r.client.Get(ctx, types.NamespacedName{Name: replicaSet.Name, Namespace: replicaSet.Namespace}, &foundReplicaSet); err != nil {
+		t.Fatalf("get replicaset: %v", err)
+	}
+
+	if foundReplicaSet.Spec.Replicas == nil || *foundReplicaSet.Spec.Replicas != 0 {
+		t.Errorf("expected the ReplicaSet to be scaled down but it has %v replicas", foundReplicaSet.Spec.Replicas)
+	}
+}
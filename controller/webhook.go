@@ -0,0 +1,159 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	operatorsv1alpha1 "github.com/plexsystems/sandbox-operator/apis/operators/v1alpha1"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// sandboxWebhook is a mutating admission webhook for Sandboxes. It records
+// who created, changed and reviewed a Sandbox, only allows members of the
+// approver group to approve or reject Sandboxes, and only allows members of the
+// budget admin group to set their budget. The user that deletes a Sandbox is
+// recorded in its status, as a deleted object cannot be changed by the webhook.
+type sandboxWebhook struct {
+	client           client.Client
+	approverGroup    string
+	budgetAdminGroup string
+}
+
+// NewSandboxWebhook returns the admission webhook that records the users that
+// act on Sandboxes and guards their approval annotations and budget. Approvals
+// are not guarded when approverGroup is empty, as they are ignored by the
+// operator, and budgets are not guarded when budgetAdminGroup is empty.
+func NewSandboxWebhook(client client.Client, approverGroup string, budgetAdminGroup string) *admission.Webhook {
+	webhook := admission.Webhook{
+		Handler: sandboxWebhook{
+			client:           client,
+			approverGroup:    approverGroup,
+			budgetAdminGroup: budgetAdminGroup,
+		},
+	}
+
+	return &webhook
+}
+
+// Handle admits a Sandbox create, update or delete request
+func (w sandboxWebhook) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation == admissionv1beta1.Delete {
+		return w.recordDeleter(ctx, req)
+	}
+
+	var sandbox unstructured.Unstructured
+	if err := sandbox.UnmarshalJSON(req.Object.Raw); err != nil {
+		return admission.Errored(http.StatusBadRequest, fmt.Errorf("decode sandbox: %w", err))
+	}
+
+	var oldSandbox unstructured.Unstructured
+	if req.Operation == admissionv1beta1.Update {
+		if err := oldSandbox.UnmarshalJSON(req.OldObject.Raw); err != nil {
+			return admission.Errored(http.StatusBadRequest, fmt.Errorf("decode old sandbox: %w", err))
+		}
+	}
+
+	annotations := sandbox.GetAnnotations()
+	oldAnnotations := oldSandbox.GetAnnotations()
+
+	approval := annotations[approvalAnnotation]
+	if approval != "" && approval != approvalApproved && approval != approvalRejected {
+		return admission.Denied(fmt.Sprintf("%s must be %s or %s", approvalAnnotation, approvalApproved, approvalRejected))
+	}
+
+	reviewed := approval != oldAnnotations[approvalAnnotation] || annotations[approvalReasonAnnotation] != oldAnnotations[approvalReasonAnnotation]
+	if reviewed && w.approverGroup != "" && !ContainsString(req.UserInfo.Groups, w.approverGroup) {
+		return admission.Denied(fmt.Sprintf("only members of %s can approve or reject sandboxes", w.approverGroup))
+	}
+
+	budget, _, _ := unstructured.NestedString(sandbox.Object, "spec", "budget")
+	oldBudget, _, _ := unstructured.NestedString(oldSandbox.Object, "spec", "budget")
+
+	// Only a changed budget is checked, so that a Sandbox saved with an invalid
+	// budget before can still be updated and deleted
+	if budget != oldBudget && budget != "" {
+		if _, err := ParseBudget(budget); err != nil {
+			return admission.Denied(err.Error())
+		}
+	}
+
+	// The owners can edit their Sandbox, so they could otherwise raise the
+	// budget to wake up a Sandbox that was hibernated for spending it
+	if budget != oldBudget && w.budgetAdminGroup != "" && !ContainsString(req.UserInfo.Groups, w.budgetAdminGroup) {
+		return admission.Denied(fmt.Sprintf("only members of %s can set the budget of sandboxes", w.budgetAdminGroup))
+	}
+
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+
+	// The users are recorded by the webhook so they cannot be set by anyone else
+	annotations[createdByAnnotation] = oldAnnotations[createdByAnnotation]
+	if req.Operation == admissionv1beta1.Create {
+		annotations[createdByAnnotation] = req.UserInfo.Username
+	}
+
+	annotations[reviewedByAnnotation] = oldAnnotations[reviewedByAnnotation]
+	if reviewed {
+		annotations[reviewedByAnnotation] = req.UserInfo.Username
+	}
+
+	// Hibernating or waking up a Sandbox is attributed to its last editor too
+	updated := !equality.Semantic.DeepEqual(sandbox.Object["spec"], oldSandbox.Object["spec"]) || annotations[HibernateAnnotation] != oldAnnotations[HibernateAnnotation]
+	annotations[updatedByAnnotation] = oldAnnotations[updatedByAnnotation]
+	if req.Operation == admissionv1beta1.Update && updated {
+		annotations[updatedByAnnotation] = req.UserInfo.Username
+	}
+
+	for _, key := range []string{createdByAnnotation, reviewedByAnnotation, updatedByAnnotation} {
+		if annotations[key] == "" {
+			delete(annotations, key)
+		}
+	}
+
+	sandbox.SetAnnotations(annotations)
+	patched, err := sandbox.MarshalJSON()
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("encode sandbox: %w", err))
+	}
+
+	return admission.PatchResponseFromRaw(req.Object.Raw, patched)
+}
+
+// recordDeleter records the user deleting the Sandbox in its status, so that
+// the deletion is audited as theirs. The first user to delete the Sandbox is
+// kept while it is being torn down.
+func (w sandboxWebhook) recordDeleter(ctx context.Context, req admission.Request) admission.Response {
+	if req.DryRun != nil && *req.DryRun {
+		return admission.Allowed("")
+	}
+
+	var sandbox operatorsv1alpha1.Sandbox
+	if err := w.client.Get(ctx, types.NamespacedName{Name: req.Name}, &sandbox); err != nil {
+		if errors.IsNotFound(err) {
+			return admission.Allowed("")
+		}
+
+		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("get sandbox: %w", err))
+	}
+
+	if sandbox.DeletionTimestamp != nil && sandbox.Status.DeletedBy != "" {
+		return admission.Allowed("")
+	}
+
+	patch := client.MergeFrom(sandbox.DeepCopy())
+	sandbox.Status.DeletedBy = req.UserInfo.Username
+	if err := w.client.Status().Patch(ctx, &sandbox, patch); err != nil && !errors.IsNotFound(err) {
+		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("record deleter: %w", err))
+	}
+
+	return admission.Allowed("")
+}
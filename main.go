@@ -2,91 +2,275 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
 	"fmt"
-	"log"
+	"io/ioutil"
+	"net/http"
+	"os"
 	"runtime"
+	"time"
 
 	"github.com/plexsystems/sandbox-operator/apis"
 	"github.com/plexsystems/sandbox-operator/controller"
 
 	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
 	kubemetrics "github.com/operator-framework/operator-sdk/pkg/kube-metrics"
-	"github.com/operator-framework/operator-sdk/pkg/leader"
+	"github.com/operator-framework/operator-sdk/pkg/log/zap"
 	"github.com/operator-framework/operator-sdk/pkg/metrics"
 	"github.com/operator-framework/operator-sdk/pkg/restmapper"
+	"github.com/spf13/pflag"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/runtime/signals"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 )
 
 var (
 	metricsHost               = "0.0.0.0"
 	metricsPort         int32 = 8383
 	operatorMetricsPort int32 = 8686
+	opsPort             int32 = 8080
+	webhookPort               = 9443
+	drainTimeout              = 25 * time.Second
 	version                   = "v0.10.1"
 )
 
+var log = logf.Log.WithName("cmd")
+
 func main() {
+	pflag.CommandLine.AddFlagSet(zap.FlagSet())
+	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
+	pflag.Parse()
+
+	logf.SetLogger(zap.Logger())
+
 	namespace, err := k8sutil.GetWatchNamespace()
 	if err != nil {
-		log.Fatalf("watch namespace: %v", err)
+		fatal(err, "Watch namespace")
+	}
+
+	operatorNamespace, err := k8sutil.GetOperatorNamespace()
+	if err != nil {
+		fatal(err, "Operator namespace")
 	}
 
 	cfg, err := config.GetConfig()
 	if err != nil {
-		log.Fatalf("get config: %v", err)
+		fatal(err, "Get config")
 	}
 
-	err = leader.Become(context.TODO(), "sandbox-operator-lock")
+	options, err := controller.GetOptions()
 	if err != nil {
-		log.Fatalf("leader promotion: %v", err)
+		fatal(err, "Controller options")
 	}
 
+	// Replicas renew a lease on the lock while they lead, so a standby takes
+	// over once the lease of a failed leader expires. The lock is a ConfigMap
+	// until controller-runtime is upgraded to a version with Lease locks.
 	mgr, err := manager.New(cfg, manager.Options{
-		Namespace:          namespace,
-		MapperProvider:     restmapper.NewDynamicRESTMapper,
-		MetricsBindAddress: fmt.Sprintf("%s:%d", metricsHost, metricsPort),
+		Namespace:               namespace,
+		MapperProvider:          restmapper.NewDynamicRESTMapper,
+		MetricsBindAddress:      fmt.Sprintf("%s:%d", metricsHost, metricsPort),
+		LeaderElection:          options.LeaderElection,
+		LeaderElectionID:        "sandbox-operator-lock",
+		LeaderElectionNamespace: operatorNamespace,
+		LeaseDuration:           &options.LeaseDuration,
+		RenewDeadline:           &options.RenewDeadline,
 	})
 	if err != nil {
-		log.Fatalf("new manager: %v", err)
+		fatal(err, "New manager")
 	}
 
 	if err := apis.AddToScheme(mgr.GetScheme()); err != nil {
-		log.Fatalf("add crd scheme: %v", err)
+		fatal(err, "Add crd scheme")
+	}
+
+	reconciler, err := controller.Add(mgr, options)
+	if err != nil {
+		fatal(err, "Add sandbox controller")
+	}
+
+	// The webhook Service sends admission requests to every replica, so the
+	// webhook is served by every replica rather than the webhook server of the
+	// manager, which only runs on the leader
+	if options.WebhookEnabled {
+		server := webhookServer{Server: &webhook.Server{Port: webhookPort}}
+		server.Register("/mutate-sandbox", controller.NewSandboxWebhook(mgr.GetClient(), options.CreationApprovalGroup, options.BudgetAdminGroup))
+
+		if err := mgr.Add(server); err != nil {
+			fatal(err, "Add webhook server")
+		}
 	}
 
-	if err := controller.Add(mgr); err != nil {
-		log.Fatalf("add sandbox controller: %v", err)
+	readinessChecks, err := reconciler.ReadinessChecks(mgr)
+	if err != nil {
+		fatal(err, "Readiness checks")
+	}
+
+	opsMux := http.NewServeMux()
+	opsMux.Handle("/healthz", controller.NewHealthHandler(reconciler.LivenessChecks()))
+	opsMux.Handle("/readyz", controller.NewHealthHandler(readinessChecks))
+	opsMux.Handle("/reports/cost", controller.NewCostReportHandler(mgr.GetClient(), mgr.GetClient()))
+
+	opsServer := httpServer{
+		address: fmt.Sprintf("%s:%d", metricsHost, opsPort),
+		handler: opsMux,
+	}
+
+	if err := mgr.Add(opsServer); err != nil {
+		fatal(err, "Add ops server")
+	}
+
+	// The API makes requests as its callers, so it is served by every replica
+	if options.APIAddress != "" {
+		apiHandler, err := controller.NewAPIHandler(mgr.GetConfig(), mgr.GetScheme(), mgr.GetRESTMapper(), mgr.GetClient(), options.APITrustedHeaders, options.APIAllowedNames)
+		if err != nil {
+			fatal(err, "New api handler")
+		}
+
+		if options.APIInsecure {
+			log.Info("Serving the API without TLS, bearer tokens are sent in plain text", "address", options.APIAddress)
+		}
+
+		apiServer := httpServer{
+			address:      options.APIAddress,
+			handler:      apiHandler,
+			certFile:     options.APITLSCertFile,
+			keyFile:      options.APITLSKeyFile,
+			clientCAFile: options.APIClientCAFile,
+		}
+
+		if err := mgr.Add(apiServer); err != nil {
+			fatal(err, "Add api server")
+		}
 	}
 
 	service, err := serveMetrics(cfg)
 	if err != nil {
-		log.Fatalf("serve metrics: %v", err)
+		fatal(err, "Serve metrics")
 	}
 
 	services := []*v1.Service{service}
-	_, err = metrics.CreateServiceMonitors(cfg, namespace, services)
+	_, err = metrics.CreateServiceMonitors(cfg, operatorNamespace, services)
 	if err != nil {
 		if err == metrics.ErrServiceMonitorNotPresent {
-			log.Println("prometheus-operator not found. skipping service monitor creation")
+			log.Info("Prometheus operator not found, skipping service monitor creation")
 		} else {
-			log.Printf("create service monitors: %v", err)
+			log.Error(err, "Create service monitors")
 		}
 	}
 
-	log.Println("Starting operator...")
-	log.Println(fmt.Sprintf("Version: %s", version))
-	log.Println(fmt.Sprintf("Go Version: %s", runtime.Version()))
-	log.Println(fmt.Sprintf("Go OS/Arch: %s/%s", runtime.GOOS, runtime.GOARCH))
+	log.Info("Starting operator", "version", version, "goVersion", runtime.Version(), "goOS", runtime.GOOS, "goArch", runtime.GOARCH)
 
 	if err := mgr.Start(signals.SetupSignalHandler()); err != nil {
-		log.Fatalf("starting operator: %s", err)
+		fatal(err, "Starting operator")
+	}
+
+	// The manager stops handing out work when it is stopped, but does not wait
+	// for the reconciles in progress, which would be cut off half way
+	log.Info("Waiting for reconciles to finish")
+	deadline := time.Now().Add(drainTimeout)
+	if !reconciler.WaitForReconciles(drainTimeout) {
+		log.Info("Stopped before reconciles finished", "timeout", drainTimeout.String())
+	}
+
+	// Audit records and notifications are sent in the background, and the
+	// last reconciles may have queued some
+	log.Info("Waiting for audit records and notifications to be sent")
+	if !reconciler.Close(time.Until(deadline)) {
+		log.Info("Stopped before audit records and notifications were sent", "timeout", drainTimeout.String())
 	}
 }
 
+func fatal(err error, msg string) {
+	log.Error(err, msg)
+	os.Exit(1)
+}
+
+// webhookServer serves the admission webhook on every replica, not only the leader
+type webhookServer struct {
+	*webhook.Server
+}
+
+// NeedLeaderElection reports that the webhook does not wait for leadership
+func (w webhookServer) NeedLeaderElection() bool {
+	return false
+}
+
+// httpServer serves the operational endpoints and the API of the operator.
+// It is run by every replica, not only the leader.
+type httpServer struct {
+	address string
+	handler http.Handler
+
+	// certFile and keyFile serve over TLS when set
+	certFile string
+	keyFile  string
+
+	// clientCAFile verifies the client certificates that callers present
+	// when set. Callers without a certificate are still served.
+	clientCAFile string
+}
+
+// NeedLeaderElection reports that the server does not wait for leadership
+func (h httpServer) NeedLeaderElection() bool {
+	return false
+}
+
+// Start serves the handler until the server is stopped
+func (h httpServer) Start(stop <-chan struct{}) error {
+	const shutdownTimeout = 5 * time.Second
+
+	server := http.Server{
+		Addr:    h.address,
+		Handler: h.handler,
+	}
+
+	if h.clientCAFile != "" {
+		clientCAs, err := ioutil.ReadFile(h.clientCAFile)
+		if err != nil {
+			return fmt.Errorf("read client CA: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(clientCAs) {
+			return fmt.Errorf("no certificates found in client CA %s", h.clientCAFile)
+		}
+
+		server.TLSConfig = &tls.Config{
+			ClientCAs:  pool,
+			ClientAuth: tls.VerifyClientCertIfGiven,
+		}
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		if h.certFile != "" {
+			errs <- server.ListenAndServeTLS(h.certFile, h.keyFile)
+			return
+		}
+
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return fmt.Errorf("listen and serve: %w", err)
+	case <-stop:
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	return server.Shutdown(ctx)
+}
+
 func serveMetrics(cfg *rest.Config) (*v1.Service, error) {
 	customResourceKinds, err := k8sutil.GetGVKsFromAddToScheme(apis.AddToScheme)
 	if err != nil {